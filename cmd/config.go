@@ -0,0 +1,37 @@
+package main
+
+import (
+	"TestAvito/internal/config"
+	"errors"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+const configUsage = "usage: avito config print [--redacted | --show-secrets]"
+
+// configCommand prints the effective configuration: config.yaml merged with
+// defaults, environment variables and *_file secrets. Secrets are redacted
+// unless --show-secrets is given; --redacted asks for the default explicitly.
+func configCommand(out io.Writer, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "print" || len(args) > 2 {
+		return errors.New(configUsage)
+	}
+
+	printed := cfg.Redacted()
+	if len(args) == 2 {
+		switch args[1] {
+		case "--redacted":
+		case "--show-secrets":
+			printed = *cfg
+		default:
+			return errors.New(configUsage)
+		}
+	}
+
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(printed); err != nil {
+		return err
+	}
+	return enc.Close()
+}
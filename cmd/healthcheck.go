@@ -0,0 +1,42 @@
+package main
+
+import (
+	"TestAvito/internal/config"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// healthcheck probes /readyz of a locally running server, so container
+// healthchecks work in images without curl or wget.
+func healthcheck(cfg config.Server) error {
+	_, port, err := net.SplitHostPort(cfg.Url)
+	if err != nil {
+		return fmt.Errorf("healthcheck: %w", err)
+	}
+
+	scheme := "http"
+	if cfg.TLSCertFile != "" {
+		scheme = "https"
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			// The certificate is issued for the public name, not for localhost.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	resp, err := client.Get(fmt.Sprintf("%s://127.0.0.1:%s/readyz", scheme, port))
+	if err != nil {
+		return fmt.Errorf("healthcheck: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("healthcheck: /readyz returned %d", resp.StatusCode)
+	}
+	return nil
+}
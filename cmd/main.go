@@ -4,10 +4,19 @@ import (
 	"TestAvito/internal/config"
 	"TestAvito/internal/database"
 	"TestAvito/internal/logger"
+	"TestAvito/internal/metrics"
 	"TestAvito/internal/storage"
+	"TestAvito/internal/version"
 	"TestAvito/internal/web"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/exp/slog"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/lib/pq"
 )
@@ -20,45 +29,170 @@ func main() {
 }
 
 func run() error {
-	cfg, err := config.LoadConfig("config.yaml")
-	if err != nil {
+	flags := flag.NewFlagSet("avito", flag.ContinueOnError)
+	configPath := flags.String("config", envOr("AVITO_CONFIG", "config.yaml"),
+		"path to the base configuration file; config.<profile>.yaml next to it is applied on top (env AVITO_CONFIG)")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
 		return err
 	}
+	args := flags.Args()
 
-	logger, err := logger.New(cfg.Logger)
+	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
 		return err
 	}
 
-	db, err := database.Connection(cfg.Database)
+	if len(args) > 0 && args[0] == "config" {
+		return configCommand(os.Stdout, cfg, args[1:])
+	}
+	// healthcheck runs in the container every few seconds and must not open
+	// or rotate the service log files.
+	if len(args) > 0 && args[0] == "healthcheck" {
+		return healthcheck(cfg.Server)
+	}
+
+	logLevel := new(slog.LevelVar)
+	logger, logFiles, err := logger.New(cfg.Logger, logLevel)
 	if err != nil {
 		return err
 	}
-	defer func() {
+	defer logFiles.Close()
+
+	// Cancelled on SIGINT/SIGTERM: stops connection retries during startup and
+	// triggers the graceful shutdown of the HTTP server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reopenLogsOnSIGHUP(ctx, logger, logFiles)
+
+	logger.Info("starting",
+		slog.String("version", version.Version),
+		slog.String("commit", version.Commit),
+		slog.String("profile", cfg.Profile),
+	)
+
+	appMetrics := metrics.New()
+	opts := []web.Option{web.WithMetrics(appMetrics), web.WithLogLevel(logLevel)}
+
+	var st *storage.Storage
+	switch cfg.Storage.Driver {
+	case "", "postgres":
+		db, err := database.Connection(ctx, cfg.Database, logger)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			sqlDB, err := db.DB()
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+			err = sqlDB.Close()
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+		}()
+
+		if len(args) > 0 && args[0] == "migrate" {
+			return migrateCommand(logger, db, args[1:])
+		}
+
+		err = database.RunMigrations(db)
+		if err != nil {
+			logger.Error("database run migrations", err.Error())
+			return err
+		}
+
 		sqlDB, err := db.DB()
 		if err != nil {
-			logger.Error(err.Error())
-			return
+			return err
 		}
-		err = sqlDB.Close()
+		err = appMetrics.RegisterDBStats(sqlDB, cfg.Database.Name)
 		if err != nil {
-			logger.Error(err.Error())
-			return
+			return err
 		}
-	}()
 
-	err = database.RunMigrations(db)
+		st = storage.New(db)
+		opts = append(opts,
+			web.WithHealthCheck("database", func(ctx context.Context) error {
+				return database.Ping(ctx, db)
+			}),
+			web.WithHealthCheck("migrations", func(ctx context.Context) error {
+				return database.CheckMigrations(ctx, db)
+			}),
+		)
+	case "memory":
+		if len(args) > 0 && args[0] == "migrate" {
+			return fmt.Errorf("migrate requires the postgres storage driver")
+		}
+
+		logger.Warn("using in-memory storage, all data is lost on restart")
+		st = storage.NewMemory()
+	default:
+		return fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+
+	if len(args) > 0 && args[0] == "reconcile" {
+		return reconcile(logger, st)
+	}
+
+	server, err := web.New(cfg.Server, cfg.JWT, cfg.Auth, logger, st, opts...)
 	if err != nil {
-		logger.Error("database run migrations", err.Error())
 		return err
 	}
 
-	st := storage.New(db)
+	return server.Serve(ctx)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
 
-	server, err := web.New(cfg.Server, cfg.JWT, logger, st)
+// reopenLogsOnSIGHUP lets logrotate move the log files away and signal the
+// service to continue in new ones.
+func reopenLogsOnSIGHUP(ctx context.Context, logger *slog.Logger, files logger.Files) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := files.Reopen(); err != nil {
+				logger.Error("reopen log files", slog.String("error", err.Error()))
+				continue
+			}
+			logger.Info("log files reopened")
+		}
+	}
+}
+
+func reconcile(logger *slog.Logger, st *storage.Storage) error {
+	discrepancies, err := st.Reconcile(context.Background())
 	if err != nil {
 		return err
 	}
 
-	return server.Serve()
+	for _, d := range discrepancies {
+		logger.Error("balance does not match ledger",
+			slog.Uint64("user_id", uint64(d.UserID)),
+			slog.String("username", d.Username),
+			slog.Int("coins", d.Coins),
+			slog.Int("ledger_balance", d.LedgerBalance),
+		)
+	}
+	if len(discrepancies) > 0 {
+		return fmt.Errorf("reconciliation failed for %d users", len(discrepancies))
+	}
+
+	logger.Info("ledger reconciled")
+	return nil
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"TestAvito/internal/database"
+	"errors"
+	"fmt"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+const migrateUsage = "usage: avito migrate up | down N | version | force VERSION"
+
+func migrateCommand(logger *slog.Logger, db *gorm.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := database.MigrateUp(db); err != nil {
+			return err
+		}
+	case "down":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		steps, err := strconv.Atoi(args[1])
+		if err != nil || steps <= 0 {
+			return fmt.Errorf("invalid number of steps %q", args[1])
+		}
+		if err := database.MigrateDown(db, steps); err != nil {
+			return err
+		}
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := database.MigrateForce(db, version); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := database.MigrationVersion(db)
+	if err != nil {
+		return err
+	}
+	logger.Info("schema version", slog.Uint64("version", uint64(version)), slog.Bool("dirty", dirty))
+
+	return nil
+}
@@ -1,59 +1,198 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
+)
+
 type Config struct {
+	Profile  string `mapstructure:"profile"`
 	Server   Server
+	Storage  Storage
 	Database Database
 	JWT      JWT
+	Auth     Auth
 	Logger   Logger
 }
 
 type Server struct {
-	Url string `mapstructure:"url"`
+	Url             string        `mapstructure:"url"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	MaxHeaderBytes  int           `mapstructure:"max_header_bytes"`
+	BodyLimit       string        `mapstructure:"body_limit"`
+	TLSCertFile     string        `mapstructure:"tls_cert_file"`
+	TLSKeyFile      string        `mapstructure:"tls_key_file"`
+	// DebugEndpoints serves net/http/pprof under /debug/pprof.
+	DebugEndpoints bool `mapstructure:"debug_endpoints"`
+}
+
+type Storage struct {
+	Driver string `mapstructure:"driver"`
 }
 
 type Database struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	Name     string `mapstructure:"dbname"`
-	SSLMode  string `mapstructure:"sslmode"`
+	Host               string        `mapstructure:"host"`
+	Port               int           `mapstructure:"port"`
+	User               string        `mapstructure:"user"`
+	Password           string        `mapstructure:"password"`
+	PasswordFile       string        `mapstructure:"password_file"`
+	Name               string        `mapstructure:"dbname"`
+	SSLMode            string        `mapstructure:"sslmode"`
+	SSLRootCert        string        `mapstructure:"sslrootcert"`
+	MaxOpenConns       int           `mapstructure:"max_open_conns"`
+	MaxIdleConns       int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime    time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnectRetries     int           `mapstructure:"connect_retries"`
+	ConnectBackoff     time.Duration `mapstructure:"connect_backoff"`
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
 }
 
 type JWT struct {
-	SecretKey      string `mapstructure:"secret_key"`
-	ExpirationTime int    `mapstructure:"expiration_time"`
+	SecretKey             string   `mapstructure:"secret_key"`
+	SecretKeyFile         string   `mapstructure:"secret_key_file"`
+	ExpirationTime        int      `mapstructure:"expiration_time"`
+	RefreshExpirationTime int      `mapstructure:"refresh_expiration_time"`
+	Issuer                string   `mapstructure:"issuer"`
+	Audience              string   `mapstructure:"audience"`
+	Algorithm             string   `mapstructure:"algorithm"`
+	SigningKeyID          string   `mapstructure:"signing_key_id"`
+	Keys                  []JWTKey `mapstructure:"keys"`
+}
+
+type JWTKey struct {
+	ID             string `mapstructure:"id"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+type Auth struct {
+	AutoRegister bool `mapstructure:"auto_register"`
 }
 
 type Logger struct {
-	Sink  string `mapstructure:"sink"`
-	Level string `mapstructure:"level"`
+	Sink     string      `mapstructure:"sink"`
+	Level    string      `mapstructure:"level"`
+	Format   string      `mapstructure:"format"`
+	Color    string      `mapstructure:"color"`
+	Sinks    []LogSink   `mapstructure:"sinks"`
+	Rotation LogRotation `mapstructure:"rotation"`
 }
 
+// LogSink is an additional log output. Empty Level and Format fall back to
+// the values of Logger; a sink with its own Level ignores runtime changes of
+// the global level.
+type LogSink struct {
+	Sink   string `mapstructure:"sink"`
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// LogRotation applies to every file sink.
+type LogRotation struct {
+	MaxSizeMB   int           `mapstructure:"max_size_mb"`
+	MaxAgeDays  int           `mapstructure:"max_age_days"`
+	MaxBackups  int           `mapstructure:"max_backups"`
+	Compress    bool          `mapstructure:"compress"`
+	RotateEvery time.Duration `mapstructure:"rotate_every"`
+}
+
+// LoadConfig reads path and then, if it exists, config.<profile>.yaml next to
+// it on top of the defaults of the selected profile. AVITO_* environment
+// variables override both files. *_file secrets are resolved and the result
+// is validated; unknown keys in either file are an error.
 func LoadConfig(path string) (*Config, error) {
 	v := viper.New()
-	v.SetConfigFile(path)
 	v.SetConfigType("yaml")
 
-	v.AutomaticEnv()
-	v.SetEnvPrefix("AVITO")
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	setDefaults(v)
+	if err := bindEnv(v); err != nil {
+		return nil, fmt.Errorf("error binding environment: %w", err)
+	}
 
+	v.SetConfigFile(path)
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading configuration: %w", err)
 	}
 
+	profile := v.GetString("profile")
+	setProfileDefaults(v, profile)
+
+	overlay := profilePath(path, profile)
+	if _, err := os.Stat(overlay); err == nil {
+		v.SetConfigFile(overlay)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("error reading configuration: %w", err)
+		}
+		if v.GetString("profile") != profile {
+			return nil, fmt.Errorf("error reading configuration: %s must not change the profile", overlay)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+
 	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	if err := v.UnmarshalExact(&cfg); err != nil {
 		return nil, fmt.Errorf("error parsing configuration: %w", err)
 	}
 
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
 	return &cfg, nil
 }
+
+// profilePath turns config/config.yaml into config/config.prod.yaml.
+func profilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// resolveSecrets replaces secrets with the contents of their *_file
+// variants, e.g. Docker or Kubernetes secrets mounted as files.
+func (c *Config) resolveSecrets() error {
+	secrets := []struct {
+		key, fileKey string
+		value, file  *string
+	}{
+		{"database.password", "database.password_file", &c.Database.Password, &c.Database.PasswordFile},
+		{"jwt.secret_key", "jwt.secret_key_file", &c.JWT.SecretKey, &c.JWT.SecretKeyFile},
+	}
+
+	var errs []error
+	for _, s := range secrets {
+		if *s.file == "" {
+			continue
+		}
+		if *s.value != "" {
+			errs = append(errs, fmt.Errorf("%s and %s are mutually exclusive", s.key, s.fileKey))
+			continue
+		}
+		b, err := os.ReadFile(*s.file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.fileKey, err))
+			continue
+		}
+		*s.value = strings.TrimRight(string(b), "\r\n")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const strongSecret = "0123456789abcdef0123456789abcdef"
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	t.Setenv("AVITO_DATABASE_PASSWORD", "password")
+	t.Setenv("AVITO_JWT_SECRET_KEY", strongSecret)
+
+	cfg, err := LoadConfig(writeConfig(t, "{}"))
+	require.NoError(t, err)
+	assert.Equal(t, ProfileProd, cfg.Profile)
+	assert.Equal(t, "0.0.0.0:8080", cfg.Server.Url)
+	assert.Equal(t, 15*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, "postgres", cfg.Storage.Driver)
+	assert.Equal(t, "password", cfg.Database.Password)
+	assert.Equal(t, 500*time.Millisecond, cfg.Database.ConnectBackoff)
+	assert.Equal(t, "info", cfg.Logger.Level)
+	assert.Equal(t, "HS256", cfg.JWT.Algorithm)
+	assert.Equal(t, strongSecret, cfg.JWT.SecretKey)
+	assert.Equal(t, 7200, cfg.JWT.ExpirationTime)
+}
+
+func TestLoadConfig_EnvOverridesFile(t *testing.T) {
+	t.Setenv("AVITO_SERVER_READ_TIMEOUT", "3s")
+	t.Setenv("AVITO_LOGGER_ROTATION_COMPRESS", "true")
+
+	cfg, err := LoadConfig(writeConfig(t, `
+profile: "dev"
+server:
+  read_timeout: "10s"
+storage:
+  driver: "memory"
+jwt:
+  secret_key: "short"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, cfg.Server.ReadTimeout)
+	assert.True(t, cfg.Logger.Rotation.Compress)
+}
+
+func TestLoadConfig_RejectsUnknownKeys(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, `
+profile: "dev"
+storage:
+  driver: "memory"
+jwt:
+  secret_key: "short"
+debug: true
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "debug")
+}
+
+func TestLoadConfig_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "db-password")
+	secretFile := filepath.Join(dir, "jwt-secret")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+	require.NoError(t, os.WriteFile(secretFile, []byte(strongSecret+"\n"), 0o600))
+	t.Setenv("AVITO_JWT_SECRET_KEY_FILE", secretFile)
+
+	cfg, err := LoadConfig(writeConfig(t, "database:\n  password_file: "+passwordFile+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", cfg.Database.Password)
+	assert.Equal(t, strongSecret, cfg.JWT.SecretKey)
+
+	_, err = LoadConfig(writeConfig(t, "database:\n  password: inline\n  password_file: "+passwordFile+"\n"))
+	assert.ErrorContains(t, err, "database.password and database.password_file are mutually exclusive")
+}
+
+func TestValidate_AggregatesErrors(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, `
+profile: "staging"
+server:
+  url: "localhost"
+  body_limit: "1 megabyte"
+database:
+  password: "password"
+  port: 0
+logger:
+  level: "verbose"
+jwt:
+  secret_key: "supersecretkeyforjwt"
+`))
+	require.Error(t, err)
+	for _, want := range []string{
+		`profile: must be one of ["dev" "test" "prod"], got "staging"`,
+		`server.url: must be host:port, got "localhost"`,
+		`server.body_limit: must be a positive size like 512K or 1M, got "1 megabyte"`,
+		"database.port: must be between 1 and 65535, got 0",
+		`logger.level: must be one of ["debug" "info" "warn" "error"], got "verbose"`,
+		`jwt.secret_key: must be at least 32 bytes outside the "dev" profile, got 20`,
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestValidate_ShortSecretAllowedInDev(t *testing.T) {
+	t.Setenv("AVITO_DATABASE_PASSWORD", "password")
+
+	_, err := LoadConfig(writeConfig(t, "profile: dev\njwt:\n  secret_key: supersecretkeyforjwt\n"))
+	assert.NoError(t, err)
+	_, err = LoadConfig(writeConfig(t, "profile: test\njwt:\n  secret_key: supersecretkeyforjwt\n"))
+	assert.ErrorContains(t, err, "jwt.secret_key")
+}
+
+func TestConfig_PrintRedacted(t *testing.T) {
+	t.Setenv("AVITO_DATABASE_PASSWORD", "db-password")
+	t.Setenv("AVITO_JWT_SECRET_KEY", strongSecret)
+	cfg, err := LoadConfig(writeConfig(t, "{}"))
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "db-password")
+	assert.NotContains(t, string(out), strongSecret)
+	assert.Contains(t, string(out), `password: "[REDACTED]"`)
+	assert.True(t, strings.HasPrefix(string(out), "profile: \"prod\"\nserver:\n"))
+	assert.Equal(t, "db-password", cfg.Database.Password, "Redacted must not modify the original")
+
+	// The printed configuration is a valid config file with the same values.
+	out, err = yaml.Marshal(cfg)
+	require.NoError(t, err)
+	reloaded, err := LoadConfig(writeConfig(t, string(out)))
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.JWT.Keys)
+	assert.Empty(t, reloaded.Logger.Sinks)
+	reloaded.JWT.Keys, reloaded.Logger.Sinks = nil, nil
+	assert.Equal(t, cfg, reloaded)
+}
+
+func TestLoadConfig_ProfileOverlay(t *testing.T) {
+	t.Setenv("AVITO_DATABASE_PASSWORD", "password")
+	t.Setenv("AVITO_JWT_SECRET_KEY", strongSecret)
+
+	path := writeConfig(t, `
+profile: "dev"
+database:
+  host: "base"
+  connect_retries: 1
+`)
+	prod := profilePath(path, ProfileProd)
+	require.Equal(t, filepath.Join(filepath.Dir(path), "config.prod.yaml"), prod)
+	require.NoError(t, os.WriteFile(prod, []byte("database:\n  host: \"prod\"\n"), 0o600))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, ProfileDev, cfg.Profile)
+	assert.Equal(t, "base", cfg.Database.Host, "the prod overlay must only apply to the prod profile")
+
+	t.Setenv("AVITO_PROFILE", ProfileProd)
+	cfg, err = LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", cfg.Database.Host)
+	assert.Equal(t, 1, cfg.Database.ConnectRetries)
+
+	t.Setenv("AVITO_DATABASE_HOST", "env")
+	cfg, err = LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "env", cfg.Database.Host)
+}
+
+func TestLoadConfig_OverlayCannotChangeProfile(t *testing.T) {
+	path := writeConfig(t, "profile: dev\n")
+	require.NoError(t, os.WriteFile(profilePath(path, ProfileDev), []byte("profile: prod\n"), 0o600))
+
+	_, err := LoadConfig(path)
+	assert.ErrorContains(t, err, "must not change the profile")
+}
+
+func TestLoadConfig_ProfileDefaults(t *testing.T) {
+	t.Setenv("AVITO_DATABASE_PASSWORD", "password")
+	t.Setenv("AVITO_JWT_SECRET_KEY", strongSecret)
+
+	for _, tt := range []struct {
+		profile        string
+		autoRegister   bool
+		debugEndpoints bool
+		level, color   string
+	}{
+		{ProfileDev, true, true, "debug", "auto"},
+		{ProfileTest, true, false, "info", "never"},
+		{ProfileProd, false, false, "info", "never"},
+	} {
+		cfg, err := LoadConfig(writeConfig(t, "profile: "+tt.profile+"\n"))
+		require.NoError(t, err, tt.profile)
+		assert.Equal(t, tt.autoRegister, cfg.Auth.AutoRegister, tt.profile)
+		assert.Equal(t, tt.debugEndpoints, cfg.Server.DebugEndpoints, tt.profile)
+		assert.Equal(t, tt.level, cfg.Logger.Level, tt.profile)
+		assert.Equal(t, tt.color, cfg.Logger.Color, tt.profile)
+	}
+
+	// Explicit values win over profile defaults.
+	cfg, err := LoadConfig(writeConfig(t, "profile: dev\nauth:\n  auto_register: false\n"))
+	require.NoError(t, err)
+	assert.False(t, cfg.Auth.AutoRegister)
+
+	_, err = LoadConfig(writeConfig(t, "profile: prod\nserver:\n  debug_endpoints: true\n"))
+	assert.ErrorContains(t, err, `server.debug_endpoints: must be disabled in the "prod" profile`)
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+const envPrefix = "AVITO"
+
+var defaults = map[string]any{
+	"profile": ProfileProd,
+
+	"server.url":              "0.0.0.0:8080",
+	"server.read_timeout":     10 * time.Second,
+	"server.write_timeout":    15 * time.Second,
+	"server.idle_timeout":     60 * time.Second,
+	"server.shutdown_timeout": 15 * time.Second,
+	"server.max_header_bytes": 1 << 20,
+	"server.body_limit":       "1M",
+	"server.tls_cert_file":    "",
+	"server.tls_key_file":     "",
+	"server.debug_endpoints":  false,
+
+	"storage.driver": "postgres",
+
+	"database.host":                 "localhost",
+	"database.port":                 5432,
+	"database.user":                 "postgres",
+	"database.password":             "",
+	"database.password_file":        "",
+	"database.dbname":               "avito",
+	"database.sslmode":              "disable",
+	"database.sslrootcert":          "",
+	"database.max_open_conns":       25,
+	"database.max_idle_conns":       25,
+	"database.conn_max_lifetime":    30 * time.Minute,
+	"database.connect_retries":      5,
+	"database.connect_backoff":      500 * time.Millisecond,
+	"database.slow_query_threshold": 200 * time.Millisecond,
+
+	"logger.sink":                  "stdout",
+	"logger.level":                 "info",
+	"logger.format":                "text",
+	"logger.color":                 "never",
+	"logger.rotation.max_size_mb":  100,
+	"logger.rotation.max_age_days": 0,
+	"logger.rotation.max_backups":  0,
+	"logger.rotation.compress":     false,
+	"logger.rotation.rotate_every": time.Duration(0),
+
+	"auth.auto_register": false,
+
+	"jwt.algorithm":               "HS256",
+	"jwt.secret_key":              "",
+	"jwt.secret_key_file":         "",
+	"jwt.expiration_time":         7200,
+	"jwt.refresh_expiration_time": 2592000,
+	"jwt.issuer":                  "merch-store",
+	"jwt.audience":                "merch-store",
+	"jwt.signing_key_id":          "",
+}
+
+// profileDefaults override defaults for a profile; values set in the files
+// or the environment still win.
+var profileDefaults = map[string]map[string]any{
+	ProfileDev: {
+		"server.debug_endpoints": true,
+		"logger.level":           "debug",
+		"logger.color":           "auto",
+		"auth.auto_register":     true,
+	},
+	ProfileTest: {
+		"auth.auto_register": true,
+	},
+}
+
+func setDefaults(v *viper.Viper) {
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+}
+
+func setProfileDefaults(v *viper.Viper, profile string) {
+	for key, value := range profileDefaults[profile] {
+		v.SetDefault(key, value)
+	}
+}
+
+// bindEnv maps every scalar key to an AVITO_* variable, e.g.
+// database.password_file to AVITO_DATABASE_PASSWORD_FILE, so that it can be
+// set from the environment even when the file does not mention it.
+func bindEnv(v *viper.Viper) error {
+	for key := range defaults {
+		if err := v.BindEnv(key, envName(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envName is the environment variable that overrides key.
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
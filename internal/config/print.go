@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted returns a copy of c with secret values replaced, safe to print.
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+	if c.JWT.SecretKey != "" {
+		c.JWT.SecretKey = redacted
+	}
+	return c
+}
+
+// MarshalYAML renders c with the same keys and order as config.yaml, so
+// that the output of `config print` can be used as a config file.
+func (c Config) MarshalYAML() (any, error) {
+	return toNode(reflect.ValueOf(c))
+}
+
+func toNode(v reflect.Value) (*yaml.Node, error) {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return scalar(yaml.Node{Tag: "!!str", Value: d.String(), Style: yaml.DoubleQuotedStyle}), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			// Untagged fields are matched by name, like mapstructure does.
+			key, ok := field.Tag.Lookup("mapstructure")
+			if !ok {
+				key = strings.ToLower(field.Name)
+			}
+			value, err := toNode(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, scalar(yaml.Node{Tag: "!!str", Value: key}), value)
+		}
+		return node, nil
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for i := 0; i < v.Len(); i++ {
+			value, err := toNode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+			node.Style = 0
+		}
+		return node, nil
+	case reflect.String:
+		return scalar(yaml.Node{Tag: "!!str", Value: v.String(), Style: yaml.DoubleQuotedStyle}), nil
+	case reflect.Int, reflect.Int64:
+		return scalar(yaml.Node{Tag: "!!int", Value: strconv.FormatInt(v.Int(), 10)}), nil
+	case reflect.Bool:
+		return scalar(yaml.Node{Tag: "!!bool", Value: strconv.FormatBool(v.Bool())}), nil
+	default:
+		return nil, fmt.Errorf("config: cannot print %s", v.Type())
+	}
+}
+
+func scalar(node yaml.Node) *yaml.Node {
+	node.Kind = yaml.ScalarNode
+	return &node
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/labstack/gommon/bytes"
+)
+
+// MinSecretKeyLength is the shortest HS256 secret accepted outside the dev
+// profile.
+const MinSecretKeyLength = 32
+
+var (
+	profiles   = []string{ProfileDev, ProfileTest, ProfileProd}
+	drivers    = []string{"postgres", "memory"}
+	sslModes   = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"text", "json", "logfmt"}
+	logColors  = []string{"auto", "always", "never"}
+	jwtAlgs    = []string{"HS256", "RS256", "EdDSA"}
+)
+
+// Validate reports every problem in c at once rather than stopping at the
+// first one.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	oneOf := func(key, value string, allowed []string) {
+		if !slices.Contains(allowed, value) {
+			add(key, "must be one of %q, got %q", allowed, value)
+		}
+	}
+	notNegative := func(key string, value int64) {
+		if value < 0 {
+			add(key, "must not be negative")
+		}
+	}
+
+	oneOf("profile", c.Profile, profiles)
+
+	if c.Server.Url == "" {
+		add("server.url", "is required")
+	} else if _, port, err := net.SplitHostPort(c.Server.Url); err != nil {
+		add("server.url", "must be host:port, got %q", c.Server.Url)
+	} else if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		add("server.url", "port must be between 1 and 65535, got %q", port)
+	}
+	// echo's BodyLimit panics on a limit it cannot parse.
+	if c.Server.BodyLimit != "" {
+		if limit, err := bytes.Parse(c.Server.BodyLimit); err != nil || limit <= 0 {
+			add("server.body_limit", "must be a positive size like 512K or 1M, got %q", c.Server.BodyLimit)
+		}
+	}
+	notNegative("server.read_timeout", int64(c.Server.ReadTimeout))
+	notNegative("server.write_timeout", int64(c.Server.WriteTimeout))
+	notNegative("server.idle_timeout", int64(c.Server.IdleTimeout))
+	notNegative("server.shutdown_timeout", int64(c.Server.ShutdownTimeout))
+	notNegative("server.max_header_bytes", int64(c.Server.MaxHeaderBytes))
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		add("server.tls_cert_file", "must be set together with server.tls_key_file")
+	}
+	if c.Server.DebugEndpoints && c.Profile == ProfileProd {
+		add("server.debug_endpoints", "must be disabled in the %q profile", ProfileProd)
+	}
+
+	oneOf("storage.driver", c.Storage.Driver, drivers)
+	if c.Storage.Driver == "postgres" {
+		db := c.Database
+		for _, field := range []struct{ key, value string }{
+			{"database.host", db.Host},
+			{"database.user", db.User},
+			{"database.password", db.Password},
+			{"database.dbname", db.Name},
+		} {
+			if field.value == "" {
+				add(field.key, "is required")
+			}
+		}
+		if db.Port <= 0 || db.Port > 65535 {
+			add("database.port", "must be between 1 and 65535, got %d", db.Port)
+		}
+		oneOf("database.sslmode", db.SSLMode, sslModes)
+		if (db.SSLMode == "verify-ca" || db.SSLMode == "verify-full") && db.SSLRootCert == "" {
+			add("database.sslrootcert", "is required for sslmode %q", db.SSLMode)
+		}
+		notNegative("database.max_open_conns", int64(db.MaxOpenConns))
+		notNegative("database.max_idle_conns", int64(db.MaxIdleConns))
+		if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+			add("database.max_idle_conns", "must not exceed database.max_open_conns")
+		}
+		notNegative("database.conn_max_lifetime", int64(db.ConnMaxLifetime))
+		notNegative("database.connect_retries", int64(db.ConnectRetries))
+		notNegative("database.connect_backoff", int64(db.ConnectBackoff))
+		notNegative("database.slow_query_threshold", int64(db.SlowQueryThreshold))
+	}
+
+	oneOf("logger.level", c.Logger.Level, logLevels)
+	oneOf("logger.format", c.Logger.Format, logFormats)
+	oneOf("logger.color", c.Logger.Color, logColors)
+	for i, sink := range c.Logger.Sinks {
+		key := fmt.Sprintf("logger.sinks[%d]", i)
+		if sink.Level != "" {
+			oneOf(key+".level", sink.Level, logLevels)
+		}
+		if sink.Format != "" {
+			oneOf(key+".format", sink.Format, logFormats)
+		}
+	}
+	notNegative("logger.rotation.max_size_mb", int64(c.Logger.Rotation.MaxSizeMB))
+	notNegative("logger.rotation.max_age_days", int64(c.Logger.Rotation.MaxAgeDays))
+	notNegative("logger.rotation.max_backups", int64(c.Logger.Rotation.MaxBackups))
+	notNegative("logger.rotation.rotate_every", int64(c.Logger.Rotation.RotateEvery))
+
+	jwt := c.JWT
+	oneOf("jwt.algorithm", jwt.Algorithm, jwtAlgs)
+	switch jwt.Algorithm {
+	case "HS256":
+		if jwt.SecretKey == "" {
+			add("jwt.secret_key", "is required for %s", jwt.Algorithm)
+		} else if len(jwt.SecretKey) < MinSecretKeyLength && c.Profile != ProfileDev {
+			add("jwt.secret_key", "must be at least %d bytes outside the %q profile, got %d",
+				MinSecretKeyLength, ProfileDev, len(jwt.SecretKey))
+		}
+	case "RS256", "EdDSA":
+		if jwt.SigningKeyID == "" {
+			add("jwt.signing_key_id", "is required for %s", jwt.Algorithm)
+		}
+		if len(jwt.Keys) == 0 {
+			add("jwt.keys", "are required for %s", jwt.Algorithm)
+		}
+	}
+	if jwt.ExpirationTime <= 0 {
+		add("jwt.expiration_time", "must be positive")
+	}
+	if jwt.RefreshExpirationTime <= 0 {
+		add("jwt.refresh_expiration_time", "must be positive")
+	}
+
+	return errors.Join(errs...)
+}
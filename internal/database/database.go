@@ -2,104 +2,113 @@ package database
 
 import (
 	"TestAvito/internal/config"
-	"TestAvito/internal/models"
+	"context"
 	"fmt"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"golang.org/x/exp/slog"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-	"gorm.io/gorm/schema"
-	"log"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 )
 
-func Connection(config config.Database) (*gorm.DB, error) {
-	log.Println(config)
-	if config.User == "" || config.Password == "" || config.Host == "" || config.Port == 0 || config.Name == "" {
+const (
+	defaultSSLMode        = "disable"
+	defaultConnectRetries = 5
+	defaultConnectBackoff = 500 * time.Millisecond
+	maxConnectBackoff     = 30 * time.Second
+)
+
+// Connection opens the connection pool and waits for the database to accept
+// connections, retrying with exponential backoff until ctx is done or the
+// retries are exhausted. SQL logging goes through logger.
+func Connection(ctx context.Context, cfg config.Database, logger *slog.Logger) (*gorm.DB, error) {
+	if cfg.User == "" || cfg.Password == "" || cfg.Host == "" || cfg.Port == 0 || cfg.Name == "" {
 		return nil, fmt.Errorf("invalid database configuration")
 	}
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC",
-		config.Host,
-		config.User,
-		config.Password,
-		config.Name,
-		config.Port,
-	)
-	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-		NamingStrategy: schema.NamingStrategy{
-			SingularTable: true,
-		},
-	}
-	var db *gorm.DB
-	var err error
-	for attempts := 0; attempts < 3; attempts++ {
-		db, err = gorm.Open(postgres.Open(dsn), gormConfig)
-		if err == nil {
-			break
-		}
-		time.Sleep(1 * time.Second)
-	}
+
+	db, err := gorm.Open(postgres.Open(dsn(cfg)), &gorm.Config{
+		Logger:               NewGormLogger(logger, cfg.SlowQueryThreshold),
+		DisableAutomaticPing: true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("gorm open error after retries: %w", err)
+		return nil, fmt.Errorf("gorm open error: %w", err)
 	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
-	err = sqlDB.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("database ping error: %w", err)
-	}
-	return db, nil
-}
-
-func RunMigrations(db *gorm.DB) error {
-	err := db.AutoMigrate(models.User{})
-	if err != nil {
-		return err
-	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
-	err = db.AutoMigrate(models.Transaction{})
-	if err != nil {
-		return err
+	retries := cfg.ConnectRetries
+	if retries <= 0 {
+		retries = defaultConnectRetries
 	}
-
-	err = db.AutoMigrate(models.Inventory{})
-	if err != nil {
-		return err
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
 	}
 
-	if !db.Migrator().HasTable(&models.Product{}) {
-		err := db.AutoMigrate(&models.Product{})
-		if err != nil {
-			return err
+	for attempt := 1; ; attempt++ {
+		err = sqlDB.PingContext(ctx)
+		if err == nil {
+			return db, nil
 		}
-
-		products := []models.Product{
-			{Name: "t-shirt", Price: 80},
-			{Name: "cup", Price: 20},
-			{Name: "book", Price: 50},
-			{Name: "pen", Price: 10},
-			{Name: "powerbank", Price: 200},
-			{Name: "hoody", Price: 300},
-			{Name: "umbrella", Price: 200},
-			{Name: "socks", Price: 10},
-			{Name: "wallet", Price: 50},
-			{Name: "pink-hoody", Price: 500},
+		if attempt >= retries {
+			break
 		}
 
-		for _, product := range products {
-			err := db.Create(&product).Error
-			if err != nil {
-				return err
-			}
+		logger.Warn("database is not available, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+		select {
+		case <-ctx.Done():
+			sqlDB.Close()
+			return nil, fmt.Errorf("database ping error: %w", ctx.Err())
+		case <-time.After(backoff):
 		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+
+	sqlDB.Close()
+	return nil, fmt.Errorf("database ping error after %d attempts: %w", retries, err)
+}
+
+// dsn builds a connection URL so that credentials with spaces or quotes need
+// no manual escaping.
+func dsn(cfg config.Database) string {
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = defaultSSLMode
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
 	}
+	query.Set("TimeZone", "UTC")
 
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+func RunMigrations(db *gorm.DB) error {
+	err := MigrateUp(db)
 	if err != nil {
-		return fmt.Errorf("db automigrate error: %w", err)
+		return fmt.Errorf("db migrate error: %w", err)
 	}
 	return nil
 }
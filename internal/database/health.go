@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"gorm.io/gorm"
+	"os"
+)
+
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckMigrations fails when the schema is dirty or behind the newest
+// migration embedded in the binary. It reads schema_migrations directly
+// rather than through golang-migrate, which takes an advisory lock that would
+// block the probe while another replica is migrating.
+func CheckMigrations(ctx context.Context, db *gorm.DB) error {
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	var state struct {
+		Version uint
+		Dirty   bool
+	}
+	err = db.WithContext(ctx).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&state).Error
+	if err != nil {
+		return err
+	}
+	version, dirty := state.Version, state.Dirty
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version < latest {
+		return fmt.Errorf("schema version %d is behind %d", version, latest)
+	}
+
+	return nil
+}
+
+// LatestMigrationVersion returns the version of the newest embedded migration.
+func LatestMigrationVersion() (uint, error) {
+	source, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		} else if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
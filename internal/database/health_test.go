@@ -0,0 +1,18 @@
+package database
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	ups, err := fs.Glob(migrationsFS, "migrations/*.up.sql")
+	require.NoError(t, err)
+
+	version, err := LatestMigrationVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint(len(ups)), version)
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// newMigrate binds golang-migrate to a dedicated connection from the gorm pool.
+// Closing the returned instance releases that connection but keeps the pool open.
+func newMigrate(db *gorm.DB) (*migrate.Migrate, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	source, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("iofs", source, "postgres", driver)
+}
+
+func withMigrate(db *gorm.DB, fn func(m *migrate.Migrate) error) error {
+	m, err := newMigrate(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return fn(m)
+}
+
+func MigrateUp(db *gorm.DB) error {
+	return withMigrate(db, func(m *migrate.Migrate) error {
+		err := m.Up()
+		if errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		}
+		return err
+	})
+}
+
+func MigrateDown(db *gorm.DB, steps int) error {
+	return withMigrate(db, func(m *migrate.Migrate) error {
+		return m.Steps(-steps)
+	})
+}
+
+func MigrateForce(db *gorm.DB, version int) error {
+	return withMigrate(db, func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+}
+
+// MigrationVersion returns the currently applied schema version. A database
+// without any applied migration reports version 0.
+func MigrationVersion(db *gorm.DB) (version uint, dirty bool, err error) {
+	err = withMigrate(db, func(m *migrate.Migrate) error {
+		version, dirty, err = m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+		return err
+	})
+	return version, dirty, err
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS purchases;
+DROP TABLE IF EXISTS inventories;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,93 @@
+-- Deployments created before versioned migrations used singular table names.
+DO $$
+BEGIN
+    IF to_regclass('public."user"') IS NOT NULL AND to_regclass('public.users') IS NULL THEN
+        ALTER TABLE "user" RENAME TO users;
+    END IF;
+    IF to_regclass('public."transaction"') IS NOT NULL AND to_regclass('public.transactions') IS NULL THEN
+        ALTER TABLE "transaction" RENAME TO transactions;
+    END IF;
+    IF to_regclass('public.inventory') IS NOT NULL AND to_regclass('public.inventories') IS NULL THEN
+        ALTER TABLE inventory RENAME TO inventories;
+    END IF;
+    IF to_regclass('public.product') IS NOT NULL AND to_regclass('public.products') IS NULL THEN
+        ALTER TABLE product RENAME TO products;
+    END IF;
+    IF to_regclass('public.purchase') IS NOT NULL AND to_regclass('public.purchases') IS NULL THEN
+        ALTER TABLE purchase RENAME TO purchases;
+    END IF;
+    IF to_regclass('public.ledger_entry') IS NOT NULL AND to_regclass('public.ledger_entries') IS NULL THEN
+        ALTER TABLE ledger_entry RENAME TO ledger_entries;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS users (
+    id       BIGSERIAL PRIMARY KEY,
+    username TEXT   NOT NULL UNIQUE,
+    password TEXT   NOT NULL,
+    coins    BIGINT NOT NULL DEFAULT 1000
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id           BIGSERIAL PRIMARY KEY,
+    from_user_id BIGINT NOT NULL,
+    to_user_id   BIGINT NOT NULL,
+    amount       BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    name  TEXT   PRIMARY KEY,
+    price BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS inventories (
+    user_id   BIGINT NOT NULL,
+    item_type TEXT   NOT NULL,
+    quantity  BIGINT NOT NULL,
+    PRIMARY KEY (user_id, item_type)
+);
+
+CREATE TABLE IF NOT EXISTS purchases (
+    id        BIGSERIAL PRIMARY KEY,
+    user_id   BIGINT NOT NULL,
+    item_type TEXT   NOT NULL,
+    quantity  BIGINT NOT NULL,
+    price     BIGINT NOT NULL,
+    amount    BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id           BIGSERIAL PRIMARY KEY,
+    account      TEXT        NOT NULL,
+    counterparty TEXT        NOT NULL,
+    amount       BIGINT      NOT NULL,
+    kind         TEXT        NOT NULL,
+    reference    TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO products (name, price) VALUES
+    ('t-shirt', 80),
+    ('cup', 20),
+    ('book', 50),
+    ('pen', 10),
+    ('powerbank', 200),
+    ('hoody', 300),
+    ('umbrella', 200),
+    ('socks', 10),
+    ('wallet', 50),
+    ('pink-hoody', 500)
+ON CONFLICT (name) DO NOTHING;
+
+-- Users created before the ledger existed get an opening balance so that
+-- reconciliation holds from the first migration on.
+WITH opening AS (
+    SELECT u.id, u.coins
+    FROM users u
+    WHERE u.coins <> 0
+      AND NOT EXISTS (SELECT 1 FROM ledger_entries l WHERE l.account = 'user:' || u.id)
+)
+INSERT INTO ledger_entries (account, counterparty, amount, kind, reference)
+SELECT 'user:' || id, 'system:issuer', coins, 'grant', 'opening-balance' FROM opening
+UNION ALL
+SELECT 'system:issuer', 'user:' || id, -coins, 'grant', 'opening-balance' FROM opening;
@@ -0,0 +1,25 @@
+DROP INDEX IF EXISTS ledger_entries_reference_idx;
+DROP INDEX IF EXISTS ledger_entries_account_idx;
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_kind_check;
+
+DROP INDEX IF EXISTS purchases_user_id_idx;
+ALTER TABLE purchases
+    DROP CONSTRAINT IF EXISTS purchases_product_fk,
+    DROP CONSTRAINT IF EXISTS purchases_user_fk,
+    DROP CONSTRAINT IF EXISTS purchases_quantity_positive;
+
+ALTER TABLE inventories
+    DROP CONSTRAINT IF EXISTS inventories_product_fk,
+    DROP CONSTRAINT IF EXISTS inventories_user_fk,
+    DROP CONSTRAINT IF EXISTS inventories_quantity_positive;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_price_non_negative;
+
+DROP INDEX IF EXISTS transactions_to_user_id_idx;
+DROP INDEX IF EXISTS transactions_from_user_id_idx;
+ALTER TABLE transactions
+    DROP CONSTRAINT IF EXISTS transactions_to_user_fk,
+    DROP CONSTRAINT IF EXISTS transactions_from_user_fk,
+    DROP CONSTRAINT IF EXISTS transactions_amount_positive;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_coins_non_negative;
@@ -0,0 +1,36 @@
+-- Older releases accepted negative transfers, so existing rows may break these
+-- two checks. NOT VALID enforces them for new writes without failing the
+-- migration; validate them once the old rows are fixed:
+--   ALTER TABLE users VALIDATE CONSTRAINT users_coins_non_negative;
+--   ALTER TABLE transactions VALIDATE CONSTRAINT transactions_amount_positive;
+ALTER TABLE users
+    ADD CONSTRAINT users_coins_non_negative CHECK (coins >= 0) NOT VALID;
+
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_amount_positive CHECK (amount > 0) NOT VALID,
+    ADD CONSTRAINT transactions_from_user_fk FOREIGN KEY (from_user_id) REFERENCES users (id),
+    ADD CONSTRAINT transactions_to_user_fk FOREIGN KEY (to_user_id) REFERENCES users (id);
+
+CREATE INDEX IF NOT EXISTS transactions_from_user_id_idx ON transactions (from_user_id);
+CREATE INDEX IF NOT EXISTS transactions_to_user_id_idx ON transactions (to_user_id);
+
+ALTER TABLE products
+    ADD CONSTRAINT products_price_non_negative CHECK (price >= 0);
+
+ALTER TABLE inventories
+    ADD CONSTRAINT inventories_quantity_positive CHECK (quantity > 0),
+    ADD CONSTRAINT inventories_user_fk FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT inventories_product_fk FOREIGN KEY (item_type) REFERENCES products (name);
+
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_quantity_positive CHECK (quantity > 0),
+    ADD CONSTRAINT purchases_user_fk FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT purchases_product_fk FOREIGN KEY (item_type) REFERENCES products (name);
+
+CREATE INDEX IF NOT EXISTS purchases_user_id_idx ON purchases (user_id);
+
+ALTER TABLE ledger_entries
+    ADD CONSTRAINT ledger_entries_kind_check CHECK (kind IN ('transfer', 'purchase', 'grant', 'refund'));
+
+CREATE INDEX IF NOT EXISTS ledger_entries_account_idx ON ledger_entries (account);
+CREATE INDEX IF NOT EXISTS ledger_entries_reference_idx ON ledger_entries (reference);
@@ -0,0 +1,2 @@
+ALTER TABLE products
+    ADD COLUMN archived BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+UPDATE users SET role = 'employee' WHERE role = 'auditor';
+
+ALTER TABLE users
+    DROP CONSTRAINT users_role_check,
+    ADD CONSTRAINT users_role_check CHECK (role IN ('employee', 'admin'));
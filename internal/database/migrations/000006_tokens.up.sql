@@ -0,0 +1,19 @@
+CREATE TABLE refresh_tokens (
+    id                      BIGSERIAL PRIMARY KEY,
+    user_id                 BIGINT      NOT NULL REFERENCES users (id),
+    token_hash              TEXT        NOT NULL UNIQUE,
+    access_token_id         TEXT        NOT NULL,
+    access_token_expires_at TIMESTAMPTZ NOT NULL,
+    expires_at              TIMESTAMPTZ NOT NULL,
+    revoked_at              TIMESTAMPTZ,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+CREATE TABLE revoked_tokens (
+    token_id   TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
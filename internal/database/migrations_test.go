@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// testDSN is the database started by docker-compose.test.yml.
+const testDSN = "host=localhost user=postgres password=password dbname=testdb port=5433 sslmode=disable"
+
+// newTestDB connects to an empty schema of the test database, so that moving
+// the schema up and down does not disturb the tests of other packages running
+// at the same time. The test is skipped when the database is not running.
+func newTestDB(t *testing.T) *gorm.DB {
+	const schema = "database_test"
+
+	open := func(dsn string) *gorm.DB {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+		if err != nil {
+			t.Skipf("postgres test database is not available: %v", err)
+		}
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+		return db
+	}
+
+	admin := open(testDSN)
+	require.NoError(t, admin.Exec("DROP SCHEMA IF EXISTS "+schema+" CASCADE").Error)
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+
+	return open(testDSN + " search_path=" + schema)
+}
+
+func TestCheckMigrations(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	assert.Error(t, CheckMigrations(ctx, db), "schema_migrations does not exist yet")
+
+	require.NoError(t, MigrateUp(db))
+	assert.NoError(t, CheckMigrations(ctx, db))
+
+	require.NoError(t, MigrateDown(db, 1))
+	assert.ErrorContains(t, CheckMigrations(ctx, db), "is behind")
+
+	require.NoError(t, db.Exec("UPDATE schema_migrations SET dirty = true").Error)
+	assert.ErrorContains(t, CheckMigrations(ctx, db), "is dirty")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, CheckMigrations(cancelled, db))
+}
+
+func TestMigrationsUpDownUp(t *testing.T) {
+	db := newTestDB(t)
+	latest, err := LatestMigrationVersion()
+	require.NoError(t, err)
+
+	require.NoError(t, MigrateUp(db))
+	version, dirty, err := MigrationVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.False(t, dirty)
+
+	require.NoError(t, MigrateDown(db, int(latest)))
+	var tables int64
+	require.NoError(t, db.Raw("SELECT count(*) FROM information_schema.tables "+
+		"WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'").Scan(&tables).Error)
+	assert.Zero(t, tables, "down migrations must drop everything the up migrations created")
+
+	require.NoError(t, MigrateUp(db))
+	version, dirty, err = MigrationVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.False(t, dirty)
+}
+
+func TestConstraintsMigrationKeepsLegacyRows(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, withMigrate(db, func(m *migrate.Migrate) error { return m.Migrate(1) }))
+
+	// A negative transfer accepted by an older release and its effect on the
+	// balances.
+	require.NoError(t, db.Exec("INSERT INTO users (id, username, password, coins) VALUES "+
+		"(1, 'alice', 'hash', 1010), (2, 'bob', 'hash', -10)").Error)
+	require.NoError(t, db.Exec("INSERT INTO transactions (from_user_id, to_user_id, amount) VALUES (1, 2, -10)").Error)
+
+	require.NoError(t, MigrateUp(db))
+
+	err := db.Exec("INSERT INTO transactions (from_user_id, to_user_id, amount) VALUES (1, 2, -5)").Error
+	assert.ErrorContains(t, err, "transactions_amount_positive")
+	err = db.Exec("UPDATE users SET coins = -1 WHERE id = 1").Error
+	assert.ErrorContains(t, err, "users_coins_non_negative")
+}
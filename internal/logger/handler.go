@@ -1,7 +1,6 @@
 package logger
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -18,55 +17,122 @@ var (
 	ErrWriteLog = errors.New("cannot write log")
 )
 
+// Handler writes human-readable lines: time, level, message and the
+// attributes as a JSON object. Colors are only used when opts.color is set.
 type Handler struct {
-	handler slog.Handler
+	opts    HandlerOpts
+	palette *palette
 	mu      *sync.Mutex
-	out     io.Writer
+	attrs   []groupedAttrs
+	groups  []string
 }
 
 type HandlerOpts struct {
-	level slog.Level
+	level slog.Leveler
 	out   io.Writer
+	color bool
 }
 
-func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.handler.Enabled(ctx, level)
+// groupedAttrs are attributes added with WithAttrs together with the groups
+// that were open at that moment.
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+type palette struct {
+	time, message, attrs   *color.Color
+	debug, info, warn, err *color.Color
+}
+
+func newPalette(enabled bool) *palette {
+	p := &palette{
+		time:    color.New(color.FgWhite),
+		message: color.New(color.FgCyan),
+		attrs:   color.New(color.FgWhite),
+		debug:   color.New(color.FgMagenta),
+		info:    color.New(color.FgBlue),
+		warn:    color.New(color.FgYellow),
+		err:     color.New(color.FgRed),
+	}
+	for _, c := range []*color.Color{p.time, p.message, p.attrs, p.debug, p.info, p.warn, p.err} {
+		if enabled {
+			c.EnableColor()
+		} else {
+			c.DisableColor()
+		}
+	}
+	return p
+}
+
+func (p *palette) level(level slog.Level) *color.Color {
+	switch {
+	case level >= slog.LevelError:
+		return p.err
+	case level >= slog.LevelWarn:
+		return p.warn
+	case level >= slog.LevelInfo:
+		return p.info
+	default:
+		return p.debug
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.level != nil {
+		minLevel = h.opts.level.Level()
+	}
+	return level >= minLevel
 }
 
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	if len(attrs) == 0 {
 		return h
 	}
-	return &Handler{handler: h.handler.WithAttrs(attrs), mu: h.mu}
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], groupedAttrs{groups: h.groups, attrs: attrs})
+	return &h2
 }
 
 func (h *Handler) WithGroup(name string) slog.Handler {
-	return &Handler{handler: h.handler.WithGroup(name), mu: h.mu}
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
 }
 
-func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	level := r.Level.String() + ":"
-
-	switch r.Level {
-	case slog.LevelDebug:
-		level = color.MagentaString(level)
-	case slog.LevelInfo:
-		level = color.BlueString(level)
-	case slog.LevelWarn:
-		level = color.YellowString(level)
-	case slog.LevelError:
-		level = color.RedString(level)
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]any)
+	for _, ga := range h.attrs {
+		addAttrs(fields, ga.groups, ga.attrs)
 	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	addAttrs(fields, h.groups, attrs)
 
-	b, err := getAttrsJSON(r)
-	if err != nil {
-		return err
+	parts := []string{
+		h.palette.time.Sprint(r.Time.Format(timeFormat)),
+		h.palette.level(r.Level).Sprint(r.Level.String() + ":"),
+		h.palette.message.Sprint(r.Message),
+	}
+	if len(fields) > 0 {
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, h.palette.attrs.Sprint(string(b)))
 	}
-	output := strings.Join([]string{color.WhiteString(r.Time.Format(timeFormat)), level,
-		color.CyanString(r.Message), color.WhiteString(string(b)), "\n"}, " ")
+	output := strings.Join(parts, " ") + "\n"
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	_, err = h.out.Write([]byte(output))
+	_, err := io.WriteString(h.opts.out, output)
 	if err != nil {
 		return errors.Join(ErrWriteLog, err)
 	}
@@ -78,32 +144,63 @@ func NewHandler(opts *HandlerOpts) *Handler {
 	if opts == nil {
 		opts = &HandlerOpts{}
 	}
-	var b bytes.Buffer
 	return &Handler{
-		handler: slog.NewJSONHandler(&b, &slog.HandlerOptions{
-			Level: opts.level,
-		}),
-		mu:  &sync.Mutex{},
-		out: opts.out,
+		opts:    *opts,
+		palette: newPalette(opts.color),
+		mu:      &sync.Mutex{},
 	}
 }
 
-func getAttrsJSON(r slog.Record) ([]byte, error) {
-	if r.NumAttrs() == 0 {
-		return []byte{}, nil
+func addAttrs(fields map[string]any, groups []string, attrs []slog.Attr) {
+	if len(attrs) == 0 {
+		return
 	}
+	for _, g := range groups {
+		sub, ok := fields[g].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			fields[g] = sub
+		}
+		fields = sub
+	}
+	for _, a := range attrs {
+		putAttr(fields, a)
+	}
+}
 
-	fields := make(map[string]interface{}, r.NumAttrs())
-	r.Attrs(func(a slog.Attr) bool {
-		fields[a.Key] = a.Value.Any()
-
-		return true
-	})
-
-	b, err := json.Marshal(fields)
-	if err != nil {
-		return nil, err
+func putAttr(fields map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
 	}
 
-	return b, nil
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key == "" {
+			for _, ga := range attrs {
+				putAttr(fields, ga)
+			}
+			return
+		}
+		sub, ok := fields[a.Key].(map[string]any)
+		if !ok {
+			sub = make(map[string]any, len(attrs))
+			fields[a.Key] = sub
+		}
+		for _, ga := range attrs {
+			putAttr(sub, ga)
+		}
+	case slog.KindDuration:
+		fields[a.Key] = a.Value.Duration().String()
+	default:
+		v := a.Value.Any()
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		fields[a.Key] = v
+	}
 }
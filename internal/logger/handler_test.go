@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"TestAvito/internal/config"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"golang.org/x/exp/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_WithAttrsAndGroups(t *testing.T) {
+	var out bytes.Buffer
+	l := slog.New(NewHandler(&HandlerOpts{level: slog.LevelDebug, out: &out}))
+
+	l.With("request_id", "id-1").
+		WithGroup("http").
+		With("method", "GET").
+		Info("request", slog.Int("status", 200), slog.Duration("took", time.Second), slog.Any("err", errors.New("boom")))
+
+	line := out.String()
+	require.True(t, strings.HasSuffix(line, "\n"))
+	assert.Contains(t, line, " INFO: request ")
+	assert.NotContains(t, line, "\x1b[", "colors must be off unless requested")
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &fields))
+	assert.Equal(t, map[string]any{
+		"request_id": "id-1",
+		"http": map[string]any{
+			"method": "GET",
+			"status": float64(200),
+			"took":   "1s",
+			"err":    "boom",
+		},
+	}, fields)
+}
+
+func TestHandler_Level(t *testing.T) {
+	var out bytes.Buffer
+	l := slog.New(NewHandler(&HandlerOpts{level: slog.LevelWarn, out: &out}))
+
+	l.Info("skipped")
+	l.Warn("written")
+	assert.NotContains(t, out.String(), "skipped")
+	assert.Contains(t, out.String(), "WARN: written")
+}
+
+func TestHandler_Color(t *testing.T) {
+	var out bytes.Buffer
+	slog.New(NewHandler(&HandlerOpts{out: &out, color: true})).Error("failed")
+	assert.Contains(t, out.String(), "\x1b[31mERROR:")
+}
+
+func TestNew_Sinks(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "app.json")
+	logfmtFile := filepath.Join(dir, "app.log")
+
+	l, files, err := New(config.Logger{
+		Level:  "info",
+		Format: "text",
+		Sinks: []config.LogSink{
+			{Sink: jsonFile, Format: "json", Level: "debug"},
+			{Sink: logfmtFile, Format: "logfmt", Level: "warn"},
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer files.Close()
+
+	l.With("user", "alice").Debug("debug")
+	l.With("user", "alice").Warn("warn")
+
+	b, err := os.ReadFile(jsonFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 2)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "debug", record["msg"])
+	assert.Equal(t, "alice", record["user"])
+
+	b, err = os.ReadFile(logfmtFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "msg=debug")
+	assert.Contains(t, string(b), "level=WARN msg=warn user=alice")
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	_, _, err := New(config.Logger{Sink: "stdout", Format: "xml"}, nil)
+	assert.Error(t, err)
+}
+
+func TestNew_UnknownLevel(t *testing.T) {
+	_, _, err := New(config.Logger{Sink: "stdout", Level: "verbose"}, nil)
+	assert.EqualError(t, err, `unknown log level "verbose"`)
+
+	_, _, err = New(config.Logger{Sinks: []config.LogSink{{Sink: "stdout", Level: "trace"}}}, nil)
+	assert.Error(t, err)
+}
+
+func TestNew_LevelVar(t *testing.T) {
+	dir := t.TempDir()
+	followsFile := filepath.Join(dir, "follows.log")
+	fixedFile := filepath.Join(dir, "fixed.log")
+
+	level := new(slog.LevelVar)
+	l, files, err := New(config.Logger{
+		Level:  "info",
+		Format: "logfmt",
+		Sinks: []config.LogSink{
+			{Sink: followsFile},
+			{Sink: fixedFile, Level: "info"},
+		},
+	}, level)
+	require.NoError(t, err)
+	defer files.Close()
+	assert.Equal(t, slog.LevelInfo, level.Level())
+
+	l.Debug("hidden")
+	level.Set(slog.LevelDebug)
+	l.Debug("shown")
+
+	b, err := os.ReadFile(followsFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "msg=hidden")
+	assert.Contains(t, string(b), "msg=shown")
+
+	b, err = os.ReadFile(fixedFile)
+	require.NoError(t, err)
+	assert.Empty(t, string(b))
+}
+
+func TestNew_Color(t *testing.T) {
+	for mode, colored := range map[string]bool{"always": true, "never": false, "auto": false} {
+		name := filepath.Join(t.TempDir(), "app.log")
+		l, files, err := New(config.Logger{Sink: name, Color: mode}, nil)
+		require.NoError(t, err)
+		l.Info("hello")
+		require.NoError(t, files.Close())
+
+		b, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, colored, strings.Contains(string(b), "\x1b["), mode)
+	}
+}
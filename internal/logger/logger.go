@@ -2,52 +2,139 @@ package logger
 
 import (
 	"TestAvito/internal/config"
+	"fmt"
+	"github.com/mattn/go-isatty"
 	"golang.org/x/exp/slog"
 	"io"
 	"os"
+	"strings"
 )
 
-const permissions = 0o644
+// ParseLevel accepts the level names used in the configuration; an empty
+// name means info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch name {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// LevelName is the inverse of ParseLevel.
+func LevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
 
-func openFile(name string) (*os.File, error) {
-	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permissions)
+// New builds the logger described by cfg and sets level to cfg.Level. Sinks
+// without their own level follow level, so changing it at runtime changes
+// what they write. The returned files must be closed on exit and reopened on
+// SIGHUP.
+func New(cfg config.Logger, level *slog.LevelVar) (*slog.Logger, Files, error) {
+	if level == nil {
+		level = new(slog.LevelVar)
+	}
+	l, err := ParseLevel(cfg.Level)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return file, nil
-}
+	level.Set(l)
 
-func New(cfg config.Logger) (*slog.Logger, error) {
-	var level slog.Level
-	switch cfg.Level {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []config.LogSink{{Sink: cfg.Sink}}
 	}
 
-	var out io.Writer
-	switch cfg.Sink {
-	case "stdout":
-		out = os.Stdout
-	default:
-		file, err := openFile(cfg.Sink)
+	var files Files
+	handlers := make(fanout, 0, len(sinks))
+	for _, sink := range sinks {
+		var sinkLevel slog.Leveler = level
+		if sink.Level != "" {
+			l, err := ParseLevel(sink.Level)
+			if err != nil {
+				files.Close()
+				return nil, nil, fmt.Errorf("sink %q: %w", sink.Sink, err)
+			}
+			sinkLevel = l
+		}
+		if sink.Format == "" {
+			sink.Format = cfg.Format
+		}
+
+		var out io.Writer
+		switch sink.Sink {
+		case "", "stdout":
+			out = os.Stdout
+		case "stderr":
+			out = os.Stderr
+		default:
+			f, err := openFile(sink.Sink, cfg.Rotation)
+			if err != nil {
+				files.Close()
+				return nil, nil, err
+			}
+			files = append(files, f)
+			out = f
+		}
+
+		h, err := newSinkHandler(sink, sinkLevel, out, useColor(cfg.Color, out))
 		if err != nil {
-			return nil, err
+			files.Close()
+			return nil, nil, err
 		}
-		out = file
+		handlers = append(handlers, h)
 	}
 
-	opts := HandlerOpts{
-		level: level,
-		out:   out,
+	if len(handlers) == 1 {
+		return slog.New(handlers[0]), files, nil
 	}
-	handler := NewHandler(&opts)
-	l := slog.New(handler)
+	return slog.New(handlers), files, nil
+}
 
-	return l, nil
+func newSinkHandler(sink config.LogSink, level slog.Leveler, out io.Writer, color bool) (slog.Handler, error) {
+	switch sink.Format {
+	case "", "text":
+		return NewHandler(&HandlerOpts{
+			level: level,
+			out:   out,
+			color: color,
+		}), nil
+	case "json":
+		return slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level}), nil
+	case "logfmt":
+		return slog.NewTextHandler(out, &slog.HandlerOptions{Level: level}), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q for sink %q", sink.Format, sink.Sink)
+	}
+}
+
+// useColor resolves logger.color for out; "auto" colors only terminals.
+func useColor(mode string, out io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return isTerminal(out)
+	}
+}
+
+// isTerminal reports whether colored output makes sense for out. NO_COLOR
+// (https://no-color.org) disables colors everywhere.
+func isTerminal(out io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
 }
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"TestAvito/internal/config"
+	"errors"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// file is a log file sink. lumberjack rotates it by size and removes old
+// backups; rotateEvery additionally starts a new file at every interval
+// boundary (for 24h that is midnight UTC).
+type file struct {
+	*lumberjack.Logger
+	mu          sync.Mutex
+	rotateEvery time.Duration
+	rotatedAt   time.Time
+	now         func() time.Time
+}
+
+const permissions = 0o644
+
+func openFile(name string, cfg config.LogRotation) (*file, error) {
+	// lumberjack opens the file on the first write; check it early so that a
+	// bad path fails at startup instead of losing every log line.
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	probe, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permissions)
+	if err != nil {
+		return nil, err
+	}
+	info, err := probe.Stat()
+	probe.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{
+		Logger: &lumberjack.Logger{
+			Filename:   name,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxAge:     cfg.MaxAgeDays,
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		},
+		rotateEvery: cfg.RotateEvery,
+		rotatedAt:   info.ModTime(),
+		now:         time.Now,
+	}
+	return f, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.rotateEvery > 0 {
+		f.mu.Lock()
+		now := f.now()
+		if now.Truncate(f.rotateEvery).After(f.rotatedAt.Truncate(f.rotateEvery)) {
+			if err := f.Logger.Rotate(); err != nil {
+				f.mu.Unlock()
+				return 0, err
+			}
+			f.rotatedAt = now
+		}
+		f.mu.Unlock()
+	}
+	return f.Logger.Write(p)
+}
+
+// Files are the log files opened by New.
+type Files []*file
+
+// Reopen closes the files; the next write opens them again by path. Call it
+// after an external tool such as logrotate has moved the files away.
+func (fs Files) Reopen() error {
+	return fs.Close()
+}
+
+func (fs Files) Close() error {
+	var errs []error
+	for _, f := range fs {
+		errs = append(errs, f.Logger.Close())
+	}
+	return errors.Join(errs...)
+}
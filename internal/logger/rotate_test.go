@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"TestAvito/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func backups(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		if e.Name() != "app.log" {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestFile_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	f, err := openFile(filepath.Join(dir, "app.log"), config.LogRotation{MaxSizeMB: 1, MaxBackups: 1})
+	require.NoError(t, err)
+	defer f.Close()
+
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for i := 0; i < 3*1024; i++ {
+		_, err := f.Write(line)
+		require.NoError(t, err)
+	}
+
+	// lumberjack removes old backups in the background.
+	assert.Eventually(t, func() bool { return len(backups(t, dir)) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestFile_RotatesByInterval(t *testing.T) {
+	dir := t.TempDir()
+	f, err := openFile(filepath.Join(dir, "app.log"), config.LogRotation{RotateEvery: 24 * time.Hour})
+	require.NoError(t, err)
+	defer f.Close()
+
+	now := time.Date(2030, 1, 1, 23, 0, 0, 0, time.UTC)
+	f.rotatedAt = now
+	f.now = func() time.Time { return now }
+
+	_, err = f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	assert.Empty(t, backups(t, dir))
+
+	now = now.Add(2 * time.Hour)
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err)
+	assert.Len(t, backups(t, dir), 1)
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(b))
+}
+
+func TestFiles_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	f, err := openFile(name, config.LogRotation{})
+	require.NoError(t, err)
+	files := Files{f}
+	defer files.Close()
+
+	_, err = f.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	// What logrotate does before sending SIGHUP.
+	require.NoError(t, os.Rename(name, name+".1"))
+	require.NoError(t, files.Reopen())
+
+	_, err = f.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(b))
+	b, err = os.ReadFile(name + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(b))
+}
+
+func TestOpenFile_InvalidPath(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+
+	_, err := openFile(filepath.Join(blocker, "app.log"), config.LogRotation{})
+	assert.Error(t, err)
+}
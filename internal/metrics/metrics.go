@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "merch"
+
+// Metrics owns a dedicated registry so that tests can create as many
+// instances as they need without clashing on the global one.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	HTTPRequests     *prometheus.CounterVec
+	HTTPDuration     *prometheus.HistogramVec
+	CoinsTransferred prometheus.Counter
+	FailedTransfers  *prometheus.CounterVec
+	Purchases        *prometheus.CounterVec
+	CoinsSpent       *prometheus.CounterVec
+	FailedPurchases  *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		HTTPDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		CoinsTransferred: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coins_transferred_total",
+			Help:      "Coins moved between employees.",
+		}),
+		FailedTransfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_failed_total",
+			Help:      "Rejected coin transfers by error code.",
+		}, []string{"reason"}),
+		Purchases: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "purchases_total",
+			Help:      "Completed purchases by product.",
+		}, []string{"product"}),
+		CoinsSpent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coins_spent_total",
+			Help:      "Coins spent on merch by product.",
+		}, []string{"product"}),
+		FailedPurchases: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "purchases_failed_total",
+			Help:      "Rejected purchases by error code.",
+		}, []string{"reason"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HTTPRequests,
+		m.HTTPDuration,
+		m.CoinsTransferred,
+		m.FailedTransfers,
+		m.Purchases,
+		m.CoinsSpent,
+		m.FailedPurchases,
+	)
+
+	return m
+}
+
+// ObserveTransfer and ObservePurchase record committed operations. Counters
+// panic when decreased, so amounts that are not positive are skipped rather
+// than turning a completed operation into an error response.
+func (m *Metrics) ObserveTransfer(amount int) {
+	if amount > 0 {
+		m.CoinsTransferred.Add(float64(amount))
+	}
+}
+
+func (m *Metrics) ObservePurchase(product string, amount int) {
+	m.Purchases.WithLabelValues(product).Inc()
+	if amount > 0 {
+		m.CoinsSpent.WithLabelValues(product).Add(float64(amount))
+	}
+}
+
+// RegisterDBStats exports the connection pool statistics of db as go_sql_*
+// gauges labeled with dbName.
+func (m *Metrics) RegisterDBStats(db *sql.DB, dbName string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveSkipsNonPositiveAmounts(t *testing.T) {
+	m := New()
+
+	assert.NotPanics(t, func() {
+		m.ObserveTransfer(10)
+		m.ObserveTransfer(-5)
+		m.ObservePurchase("cup", 20)
+		m.ObservePurchase("cup", -1006)
+	})
+
+	assert.Equal(t, 10.0, testutil.ToFloat64(m.CoinsTransferred))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.Purchases.WithLabelValues("cup")))
+	assert.Equal(t, 20.0, testutil.ToFloat64(m.CoinsSpent.WithLabelValues("cup")))
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	LedgerKindTransfer = "transfer"
+	LedgerKindPurchase = "purchase"
+	LedgerKindGrant    = "grant"
+	LedgerKindRefund   = "refund"
+)
+
+const (
+	AccountIssuer = "system:issuer"
+	AccountStore  = "system:store"
+)
+
+type LedgerEntry struct {
+	ID           uint      `gorm:"primaryKey"`
+	Account      string    `gorm:"not null;index"`
+	Counterparty string    `gorm:"not null"`
+	Amount       int       `gorm:"not null"`
+	Kind         string    `gorm:"not null"`
+	Reference    string    `gorm:"not null;index"`
+	CreatedAt    time.Time `gorm:"not null"`
+}
+
+type BalanceDiscrepancy struct {
+	UserID        uint   `json:"user_id"`
+	Username      string `json:"username"`
+	Coins         int    `json:"coins"`
+	LedgerBalance int    `json:"ledger_balance"`
+}
+
+func UserAccount(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
@@ -1,6 +1,7 @@
 package models
 
 type Product struct {
-	Name  string `gorm:"primaryKey;not null"`
-	Price int    `gorm:"not null"`
+	Name     string `gorm:"primaryKey;not null"`
+	Price    int    `gorm:"not null"`
+	Archived bool   `gorm:"not null;default:false"`
 }
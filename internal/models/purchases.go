@@ -0,0 +1,10 @@
+package models
+
+type Purchase struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"not null;index"`
+	ItemType string `gorm:"not null"`
+	Quantity int    `gorm:"not null"`
+	Price    int    `gorm:"not null"`
+	Amount   int    `gorm:"not null"`
+}
@@ -1,15 +1,37 @@
 package models
 
 type AuthorizeUserRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,username"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RegisterUserRequest struct {
+	Username string `json:"username" validate:"required,username"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type SendCoinRequest struct {
-	RecipientUsername string `json:"recipient_username" validate:"required"`
-	Amount            int    `json:"amount" validate:"required,min=1"`
+	RecipientUsername string `json:"recipient_username" validate:"required,username"`
+	Amount            int    `json:"amount" validate:"required,min=1,max=100000"`
 }
 
 type BuyItemRequest struct {
-	Quantity int `json:"quantity" validate:"required, min=1"`
+	Quantity int `json:"quantity" query:"quantity" validate:"required,min=1,max=10000"`
+}
+
+type UpsertProductRequest struct {
+	Name  string `json:"name" validate:"required,max=64"`
+	Price *int   `json:"price" validate:"required,min=0"`
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=debug info warn error"`
 }
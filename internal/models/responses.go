@@ -1,11 +1,52 @@
 package models
 
 type TransactionsFromUser struct {
-	ToUser string `json:"to_user"`
+	ToUser string `json:"toUser"`
 	Amount int    `json:"amount"`
 }
 
 type TransactionsToUser struct {
-	FromUser string `json:"from_user"`
+	FromUser string `json:"fromUser"`
 	Amount   int    `json:"amount"`
 }
+
+type InventoryItem struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+}
+
+type CoinHistory struct {
+	Received []TransactionsToUser   `json:"received"`
+	Sent     []TransactionsFromUser `json:"sent"`
+}
+
+type InfoResponse struct {
+	Coins       int             `json:"coins"`
+	Inventory   []InventoryItem `json:"inventory"`
+	CoinHistory CoinHistory     `json:"coinHistory"`
+}
+
+type SendCoinResponse struct {
+	ToUser string `json:"toUser"`
+	Amount int    `json:"amount"`
+	Coins  int    `json:"coins"`
+}
+
+type BuyItemResponse struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+	Price    int    `json:"price"`
+	Total    int    `json:"total"`
+	Coins    int    `json:"coins"`
+	Owned    int    `json:"owned"`
+}
+
+type ProductInfo struct {
+	Name      string `json:"name"`
+	Price     int    `json:"price"`
+	Available bool   `json:"available"`
+}
+
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
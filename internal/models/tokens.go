@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID                   uint      `gorm:"primaryKey"`
+	UserID               uint      `gorm:"not null;index"`
+	TokenHash            string    `gorm:"not null;uniqueIndex"`
+	AccessTokenID        string    `gorm:"not null"`
+	AccessTokenExpiresAt time.Time `gorm:"not null"`
+	ExpiresAt            time.Time `gorm:"not null"`
+	RevokedAt            *time.Time
+	CreatedAt            time.Time `gorm:"not null"`
+}
+
+type RevokedToken struct {
+	TokenID   string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -1,8 +1,15 @@
 package models
 
+const (
+	RoleEmployee = "employee"
+	RoleAdmin    = "admin"
+	RoleAuditor  = "auditor"
+)
+
 type User struct {
 	ID       uint   `gorm:"primaryKey"`
 	Username string `gorm:"unique;not null"`
 	Password string `gorm:"not null"`
 	Coins    int    `gorm:"default: 1000"`
+	Role     string `gorm:"not null;default:employee"`
 }
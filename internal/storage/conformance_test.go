@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"TestAvito/internal/database"
+	"TestAvito/internal/models"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// runConformance checks the behaviour every Storage backend must share. Each
+// subtest gets a fresh, empty storage from newStorage.
+func runConformance(t *testing.T, newStorage func(t *testing.T) *Storage) {
+	ctx := context.Background()
+
+	t.Run("Users", func(t *testing.T) {
+		st := newStorage(t)
+
+		user, err := st.CreateUser("alice", "hash")
+		require.NoError(t, err)
+		assert.Equal(t, 1000, user.Coins)
+		assert.Equal(t, models.RoleEmployee, user.Role)
+
+		_, err = st.CreateUser("alice", "hash")
+		assert.ErrorIs(t, err, ErrUserExists)
+
+		byName, err := st.GetUserByUsername("alice")
+		require.NoError(t, err)
+		byID, err := st.GetUserByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, byName, byID)
+
+		_, err = st.GetUserByUsername("nobody")
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		_, err = st.GetUserByID(user.ID + 100)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+
+		_, err = st.UpdateUser(&models.User{ID: user.ID, Role: models.RoleAdmin, Coins: 5})
+		require.NoError(t, err)
+		updated, err := st.GetUserByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.RoleAdmin, updated.Role)
+		assert.Equal(t, 1000, updated.Coins)
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		st := newStorage(t)
+		alice, _ := st.CreateUser("alice", "hash")
+		bob, _ := st.CreateUser("bob", "hash")
+		_, _ = st.CreateUser("carol", "hash")
+
+		transaction, err := st.Transfer(ctx, "alice", "bob", 100)
+		require.NoError(t, err)
+		assert.Equal(t, alice.ID, transaction.FromUserID)
+		assert.Equal(t, bob.ID, transaction.ToUserID)
+		_, err = st.Transfer(ctx, "alice", "bob", 50)
+		require.NoError(t, err)
+		_, err = st.Transfer(ctx, "alice", "carol", 30)
+		require.NoError(t, err)
+
+		_, err = st.Transfer(ctx, "alice", "alice", 1)
+		assert.ErrorIs(t, err, ErrSelfTransfer)
+		_, err = st.Transfer(ctx, "alice", "bob", 0)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+		_, err = st.Transfer(ctx, "nobody", "bob", 1)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		_, err = st.Transfer(ctx, "alice", "nobody", 1)
+		assert.ErrorIs(t, err, ErrRecipientNotFound)
+		_, err = st.Transfer(ctx, "alice", "bob", 1000)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		alice, _ = st.GetUserByUsername("alice")
+		bob, _ = st.GetUserByUsername("bob")
+		assert.Equal(t, 820, alice.Coins)
+		assert.Equal(t, 1150, bob.Coins)
+
+		sent, err := st.GetGiftsGivenByUser(alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []models.TransactionsFromUser{{ToUser: "bob", Amount: 150}, {ToUser: "carol", Amount: 30}}, sent)
+
+		received, err := st.GetGiftsGivenToUser(bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []models.TransactionsToUser{{FromUser: "alice", Amount: 150}}, received)
+	})
+
+	t.Run("ConcurrentTransfers", func(t *testing.T) {
+		st := newStorage(t)
+		_, _ = st.CreateUser("alice", "hash")
+		_, _ = st.CreateUser("bob", "hash")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 30; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = st.Transfer(ctx, "alice", "bob", 100)
+			}()
+		}
+		wg.Wait()
+
+		alice, _ := st.GetUserByUsername("alice")
+		bob, _ := st.GetUserByUsername("bob")
+		assert.Equal(t, 0, alice.Coins)
+		assert.Equal(t, 2000, bob.Coins)
+	})
+
+	t.Run("Purchase", func(t *testing.T) {
+		st := newStorage(t)
+		user, _ := st.CreateUser("alice", "hash")
+		_, err := st.UpsertProduct("cup", 20)
+		require.NoError(t, err)
+		_, err = st.UpsertProduct("vase", 2000)
+		require.NoError(t, err)
+
+		purchase, inventory, err := st.Purchase(ctx, user.ID, "cup", 2)
+		require.NoError(t, err)
+		assert.Equal(t, 20, purchase.Price)
+		assert.Equal(t, 40, purchase.Amount)
+		assert.Equal(t, 2, inventory.Quantity)
+
+		_, inventory, err = st.Purchase(ctx, user.ID, "cup", 1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, inventory.Quantity)
+
+		_, _, err = st.Purchase(ctx, user.ID, "cup", 0)
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+		_, _, err = st.Purchase(ctx, user.ID, "sofa", 1)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+		_, _, err = st.Purchase(ctx, user.ID, "vase", 1)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		// 20 * 922337203685477530 wraps around to -1016 in an int64.
+		_, _, err = st.Purchase(ctx, user.ID, "cup", 922337203685477530)
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+		_, err = st.UpsertProduct("leaflet", 0)
+		require.NoError(t, err)
+		_, _, err = st.Purchase(ctx, user.ID, "leaflet", 1)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+
+		require.NoError(t, st.ArchiveProduct("cup"))
+		_, _, err = st.Purchase(ctx, user.ID, "cup", 1)
+		assert.ErrorIs(t, err, ErrProductArchived)
+
+		_, err = st.UpdateInventory(user.ID, "vase", 1)
+		assert.ErrorIs(t, err, ErrItemNotOwned)
+
+		items, err := st.GetPurchasedItems(user.ID)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, "cup", items[0].ItemType)
+		assert.Equal(t, 3, items[0].Quantity)
+
+		user, _ = st.GetUserByID(user.ID)
+		assert.Equal(t, 940, user.Coins)
+	})
+
+	t.Run("Ledger", func(t *testing.T) {
+		st := newStorage(t)
+		alice, _ := st.CreateUser("alice", "hash")
+		_, _ = st.CreateUser("bob", "hash")
+		_, _ = st.UpsertProduct("pen", 10)
+		_, err := st.Transfer(ctx, "alice", "bob", 100)
+		require.NoError(t, err)
+		_, _, err = st.Purchase(ctx, alice.ID, "pen", 3)
+		require.NoError(t, err)
+
+		entries, err := st.GetLedgerEntries(models.UserAccount(alice.ID))
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, models.LedgerKindGrant, entries[0].Kind)
+		assert.Equal(t, -100, entries[1].Amount)
+		assert.Equal(t, models.AccountStore, entries[2].Counterparty)
+
+		discrepancies, err := st.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, discrepancies)
+	})
+
+	t.Run("Products", func(t *testing.T) {
+		st := newStorage(t)
+
+		_, err := st.UpsertProduct("sticker", 5)
+		require.NoError(t, err)
+		price, err := st.GetItemPrice("sticker")
+		require.NoError(t, err)
+		assert.Equal(t, 5, price)
+
+		_, err = st.GetItemPrice("sofa")
+		assert.ErrorIs(t, err, ErrProductNotFound)
+
+		require.NoError(t, st.ArchiveProduct("sticker"))
+		assert.ErrorIs(t, st.ArchiveProduct("sofa"), ErrProductNotFound)
+
+		product, err := st.UpsertProduct("sticker", 7)
+		require.NoError(t, err)
+		assert.False(t, product.Archived)
+
+		products, err := st.ListProducts()
+		require.NoError(t, err)
+		var found bool
+		for i, p := range products {
+			if i > 0 {
+				assert.Less(t, products[i-1].Name, p.Name)
+			}
+			if p.Name == "sticker" {
+				found = true
+				assert.Equal(t, 7, p.Price)
+				assert.False(t, p.Archived)
+			}
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("Tokens", func(t *testing.T) {
+		st := newStorage(t)
+		user, _ := st.CreateUser("alice", "hash")
+		now := time.Now()
+
+		newToken := func(hash, accessTokenID string) {
+			err := st.CreateRefreshToken(&models.RefreshToken{
+				UserID:               user.ID,
+				TokenHash:            hash,
+				AccessTokenID:        accessTokenID,
+				AccessTokenExpiresAt: now.Add(time.Hour),
+				ExpiresAt:            now.Add(24 * time.Hour),
+			})
+			require.NoError(t, err)
+		}
+		newToken("hash-1", "jti-1")
+		newToken("hash-2", "jti-2")
+		newToken("hash-3", "jti-3")
+		newToken("hash-4", "jti-6")
+
+		// Expiry follows the caller's clock, not the wall clock.
+		_, err := st.UseRefreshToken(ctx, "hash-4", now.Add(25*time.Hour))
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		token, err := st.UseRefreshToken(ctx, "hash-1", now)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, token.UserID)
+		_, err = st.UseRefreshToken(ctx, "hash-1", now)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		_, err = st.UseRefreshToken(ctx, "unknown", now)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		revoked, err := st.IsTokenRevoked("jti-1")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+
+		require.NoError(t, st.RevokeRefreshToken(ctx, "hash-2", now))
+		_, err = st.UseRefreshToken(ctx, "hash-2", now)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		require.NoError(t, st.RevokeUserSessions(ctx, user.ID, now))
+		revoked, err = st.IsTokenRevoked("jti-3")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+
+		require.NoError(t, st.RevokeAccessToken("jti-4", now.Add(time.Hour)))
+		require.NoError(t, st.RevokeAccessToken("jti-4", now.Add(time.Hour)))
+		revoked, err = st.IsTokenRevoked("jti-4")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		revoked, err = st.IsTokenRevoked("jti-5")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		require.NoError(t, st.PruneExpiredTokens(ctx, now.Add(2*time.Hour)))
+		revoked, err = st.IsTokenRevoked("jti-4")
+		require.NoError(t, err)
+		assert.False(t, revoked, "expired revocations are pruned")
+		assert.Error(t, st.CreateRefreshToken(&models.RefreshToken{
+			UserID: user.ID, TokenHash: "hash-1", AccessTokenID: "jti-7",
+			AccessTokenExpiresAt: now.Add(time.Hour), ExpiresAt: now.Add(24 * time.Hour),
+		}), "refresh tokens are kept until they expire")
+
+		require.NoError(t, st.PruneExpiredTokens(ctx, now.Add(25*time.Hour)))
+		newToken("hash-1", "jti-7")
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) *Storage {
+		return NewMemory()
+	})
+}
+
+func TestPostgresConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) *Storage {
+		return New(getMigratedTestDB(t))
+	})
+}
+
+// getMigratedTestDB connects to a fresh schema of the test database built by
+// the embedded migrations, so the suite runs against the production
+// constraints rather than AutoMigrate's approximation of them.
+func getMigratedTestDB(t *testing.T) *gorm.DB {
+	const schema = "storage_conformance"
+
+	db := getTestDB(t)
+	require.NoError(t, db.Exec("DROP SCHEMA IF EXISTS "+schema+" CASCADE").Error)
+	require.NoError(t, db.Exec("CREATE SCHEMA "+schema).Error)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	db, err = gorm.Open(postgres.Open(testDSN+" search_path="+schema), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err = db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, database.MigrateUp(db))
+	return db
+}
@@ -0,0 +1,18 @@
+package storage
+
+import "errors"
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserExists        = errors.New("user already exists")
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrProductNotFound   = errors.New("product not found")
+	ErrProductArchived   = errors.New("product is no longer available")
+	ErrItemNotOwned      = errors.New("user has no such item")
+	ErrSelfTransfer      = errors.New("cannot transfer coins to yourself")
+	ErrInvalidAmount     = errors.New("amount must be positive")
+	ErrInvalidQuantity   = errors.New("quantity must be positive")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+)
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"TestAvito/internal/models"
+	"errors"
 	"gorm.io/gorm"
 )
 
@@ -33,7 +34,9 @@ func (s *InventoryRepo) CreateInventory(userID uint, itemType string, quantity i
 func (s *InventoryRepo) UpdateInventory(userID uint, itemType string, quantity int) (*models.Inventory, error) {
 	var existingInventory models.Inventory
 	err := s.db.Where("user_id = ? AND item_type = ?", userID, itemType).First(&existingInventory).Error
-	if err != nil {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrItemNotOwned
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -49,7 +52,7 @@ func (s *InventoryRepo) UpdateInventory(userID uint, itemType string, quantity i
 func (s *InventoryRepo) GetPurchasedItems(userID uint) ([]models.Inventory, error) {
 	var inventory []models.Inventory
 
-	err := s.db.Where("user_id = ?", userID).Find(&inventory).Error
+	err := s.db.Where("user_id = ?", userID).Order("item_type").Find(&inventory).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"TestAvito/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+type LedgerRepo struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepo(db *gorm.DB) *LedgerRepo {
+	return &LedgerRepo{
+		db: db,
+	}
+}
+
+func (s *LedgerRepo) GetLedgerEntries(account string) ([]models.LedgerEntry, error) {
+	var entries []models.LedgerEntry
+
+	err := s.db.Where("account = ?", account).Order("id").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Reconcile compares every user's balance with the sum of their ledger entries
+// and returns the users for which the two disagree. Both are read from one
+// REPEATABLE READ snapshot, so operations committed in between do not show up
+// as discrepancies.
+func (s *LedgerRepo) Reconcile(ctx context.Context) ([]models.BalanceDiscrepancy, error) {
+	var balances []struct {
+		Account string
+		Balance int
+	}
+	var users []models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.LedgerEntry{}).
+			Select("account, SUM(amount) AS balance").
+			Group("account").
+			Scan(&balances).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Select("id", "username", "coins").Order("id").Find(&users).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := make(map[string]int, len(balances))
+	total := 0
+	for _, b := range balances {
+		ledger[b.Account] = b.Balance
+		total += b.Balance
+	}
+	if total != 0 {
+		return nil, fmt.Errorf("ledger is unbalanced: entries sum to %d", total)
+	}
+
+	var discrepancies []models.BalanceDiscrepancy
+	for _, user := range users {
+		balance := ledger[models.UserAccount(user.ID)]
+		if balance != user.Coins {
+			discrepancies = append(discrepancies, models.BalanceDiscrepancy{
+				UserID:        user.ID,
+				Username:      user.Username,
+				Coins:         user.Coins,
+				LedgerBalance: balance,
+			})
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// postEntries records a movement of amount coins from one account to another
+// as a balanced pair of ledger entries. It must be called inside the database
+// transaction that changes the balances.
+func postEntries(tx *gorm.DB, kind, reference, from, to string, amount int) error {
+	entries := []models.LedgerEntry{
+		{Account: from, Counterparty: to, Amount: -amount, Kind: kind, Reference: reference},
+		{Account: to, Counterparty: from, Amount: amount, Kind: kind, Reference: reference},
+	}
+	return tx.Create(&entries).Error
+}
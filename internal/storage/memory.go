@@ -0,0 +1,576 @@
+package storage
+
+import (
+	"TestAvito/internal/models"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultProducts mirrors the catalog seeded by the initial migration.
+var defaultProducts = []models.Product{
+	{Name: "t-shirt", Price: 80},
+	{Name: "cup", Price: 20},
+	{Name: "book", Price: 50},
+	{Name: "pen", Price: 10},
+	{Name: "powerbank", Price: 200},
+	{Name: "hoody", Price: 300},
+	{Name: "umbrella", Price: 200},
+	{Name: "socks", Price: 10},
+	{Name: "wallet", Price: 50},
+	{Name: "pink-hoody", Price: 500},
+}
+
+// MemoryStore keeps all data in process memory. A single mutex guards every
+// operation, which gives transfers and purchases the same atomicity as the
+// database transactions in the gorm repositories.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	users         map[uint]*models.User
+	userIDs       map[string]uint
+	transactions  []models.Transaction
+	inventory     map[uint]map[string]int
+	purchases     []models.Purchase
+	ledger        []models.LedgerEntry
+	products      map[string]*models.Product
+	refreshTokens map[string]*models.RefreshToken
+	revokedTokens map[string]time.Time
+
+	nextUserID         uint
+	nextRefreshTokenID uint
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		users:         make(map[uint]*models.User),
+		userIDs:       make(map[string]uint),
+		inventory:     make(map[uint]map[string]int),
+		products:      make(map[string]*models.Product),
+		refreshTokens: make(map[string]*models.RefreshToken),
+		revokedTokens: make(map[string]time.Time),
+	}
+	for _, product := range defaultProducts {
+		product := product
+		s.products[product.Name] = &product
+	}
+	return s
+}
+
+// NewMemory returns a Storage backed by a fresh MemoryStore with the default
+// product catalog.
+func NewMemory() *Storage {
+	s := NewMemoryStore()
+	return &Storage{
+		UserStorage:        s,
+		TransactionStorage: s,
+		InventoryStorage:   s,
+		PurchaseStorage:    s,
+		LedgerStorage:      s,
+		TokenStorage:       s,
+		ProductStorage:     s,
+	}
+}
+
+func (s *MemoryStore) CreateUser(username, password string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userIDs[username]; ok {
+		return nil, ErrUserExists
+	}
+
+	s.nextUserID++
+	user := &models.User{
+		ID:       s.nextUserID,
+		Username: username,
+		Password: password,
+		Coins:    1000,
+		Role:     models.RoleEmployee,
+	}
+	s.users[user.ID] = user
+	s.userIDs[username] = user.ID
+	s.postEntries(models.LedgerKindGrant, "signup", models.AccountIssuer, models.UserAccount(user.ID), user.Coins)
+
+	copied := *user
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.userIDs[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	copied := *s.users[id]
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetUserByID(id uint) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+func (s *MemoryStore) UpdateUser(updatedUser *models.User) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateUser(updatedUser)
+}
+
+// updateUser applies the non-zero fields of updatedUser like gorm's Updates
+// does, and never touches coins.
+func (s *MemoryStore) updateUser(updatedUser *models.User) (*models.User, error) {
+	user, ok := s.users[updatedUser.ID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if updatedUser.Username != "" && updatedUser.Username != user.Username {
+		if _, taken := s.userIDs[updatedUser.Username]; taken {
+			return nil, ErrUserExists
+		}
+		delete(s.userIDs, user.Username)
+		s.userIDs[updatedUser.Username] = user.ID
+		user.Username = updatedUser.Username
+	}
+	if updatedUser.Password != "" {
+		user.Password = updatedUser.Password
+	}
+	if updatedUser.Role != "" {
+		user.Role = updatedUser.Role
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+func (s *MemoryStore) Transfer(ctx context.Context, fromUsername, toUsername string, amount int) (*models.Transaction, error) {
+	if fromUsername == toUsername {
+		return nil, ErrSelfTransfer
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	senderID, ok := s.userIDs[fromUsername]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	recipientID, ok := s.userIDs[toUsername]
+	if !ok {
+		return nil, ErrRecipientNotFound
+	}
+	sender, recipient := s.users[senderID], s.users[recipientID]
+	if sender.Coins < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	sender.Coins -= amount
+	recipient.Coins += amount
+
+	transaction := models.Transaction{
+		ID:         uint(len(s.transactions) + 1),
+		FromUserID: sender.ID,
+		ToUserID:   recipient.ID,
+		Amount:     amount,
+	}
+	s.transactions = append(s.transactions, transaction)
+	s.postEntries(models.LedgerKindTransfer, fmt.Sprintf("transaction:%d", transaction.ID),
+		models.UserAccount(sender.ID), models.UserAccount(recipient.ID), amount)
+
+	return &transaction, nil
+}
+
+func (s *MemoryStore) GetGiftsGivenByUser(userID uint) ([]models.TransactionsFromUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sums := make(map[string]int)
+	for _, t := range s.transactions {
+		if t.FromUserID == userID {
+			if user, ok := s.users[t.ToUserID]; ok {
+				sums[user.Username] += t.Amount
+			}
+		}
+	}
+
+	var result []models.TransactionsFromUser
+	for username, amount := range sums {
+		result = append(result, models.TransactionsFromUser{ToUser: username, Amount: amount})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ToUser < result[j].ToUser })
+
+	return result, nil
+}
+
+func (s *MemoryStore) GetGiftsGivenToUser(userID uint) ([]models.TransactionsToUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sums := make(map[string]int)
+	for _, t := range s.transactions {
+		if t.ToUserID == userID {
+			if user, ok := s.users[t.FromUserID]; ok {
+				sums[user.Username] += t.Amount
+			}
+		}
+	}
+
+	var result []models.TransactionsToUser
+	for username, amount := range sums {
+		result = append(result, models.TransactionsToUser{FromUser: username, Amount: amount})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FromUser < result[j].FromUser })
+
+	return result, nil
+}
+
+func (s *MemoryStore) CreateInventory(userID uint, itemType string, quantity int) (*models.Inventory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inventory[userID][itemType]; ok {
+		return nil, fmt.Errorf("inventory %d/%s already exists", userID, itemType)
+	}
+	if s.inventory[userID] == nil {
+		s.inventory[userID] = make(map[string]int)
+	}
+	s.inventory[userID][itemType] = quantity
+
+	return &models.Inventory{UserID: userID, ItemType: itemType, Quantity: quantity}, nil
+}
+
+func (s *MemoryStore) UpdateInventory(userID uint, itemType string, quantity int) (*models.Inventory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.inventory[userID][itemType]
+	if !ok {
+		return nil, ErrItemNotOwned
+	}
+	s.inventory[userID][itemType] = current + quantity
+
+	return &models.Inventory{UserID: userID, ItemType: itemType, Quantity: current + quantity}, nil
+}
+
+func (s *MemoryStore) GetPurchasedItems(userID uint) ([]models.Inventory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var inventory []models.Inventory
+	for itemType, quantity := range s.inventory[userID] {
+		inventory = append(inventory, models.Inventory{UserID: userID, ItemType: itemType, Quantity: quantity})
+	}
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].ItemType < inventory[j].ItemType })
+
+	return inventory, nil
+}
+
+func (s *MemoryStore) Purchase(ctx context.Context, userID uint, itemType string, quantity int) (*models.Purchase, *models.Inventory, error) {
+	if quantity <= 0 {
+		return nil, nil, ErrInvalidQuantity
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, nil, ErrUserNotFound
+	}
+	product, ok := s.products[itemType]
+	if !ok {
+		return nil, nil, ErrProductNotFound
+	}
+	if product.Archived {
+		return nil, nil, ErrProductArchived
+	}
+
+	amount, err := purchaseAmount(product.Price, quantity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Coins < amount {
+		return nil, nil, ErrInsufficientFunds
+	}
+
+	user.Coins -= amount
+	if s.inventory[userID] == nil {
+		s.inventory[userID] = make(map[string]int)
+	}
+	s.inventory[userID][itemType] += quantity
+
+	purchase := models.Purchase{
+		ID:       uint(len(s.purchases) + 1),
+		UserID:   userID,
+		ItemType: itemType,
+		Quantity: quantity,
+		Price:    product.Price,
+		Amount:   amount,
+	}
+	s.purchases = append(s.purchases, purchase)
+	s.postEntries(models.LedgerKindPurchase, fmt.Sprintf("purchase:%d", purchase.ID),
+		models.UserAccount(userID), models.AccountStore, amount)
+
+	inventory := models.Inventory{UserID: userID, ItemType: itemType, Quantity: s.inventory[userID][itemType]}
+	return &purchase, &inventory, nil
+}
+
+func (s *MemoryStore) GetLedgerEntries(account string) ([]models.LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []models.LedgerEntry
+	for _, entry := range s.ledger {
+		if entry.Account == account {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *MemoryStore) Reconcile(ctx context.Context) ([]models.BalanceDiscrepancy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ledger := make(map[string]int)
+	total := 0
+	for _, entry := range s.ledger {
+		ledger[entry.Account] += entry.Amount
+		total += entry.Amount
+	}
+	if total != 0 {
+		return nil, fmt.Errorf("ledger is unbalanced: entries sum to %d", total)
+	}
+
+	ids := make([]uint, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var discrepancies []models.BalanceDiscrepancy
+	for _, id := range ids {
+		user := s.users[id]
+		balance := ledger[models.UserAccount(user.ID)]
+		if balance != user.Coins {
+			discrepancies = append(discrepancies, models.BalanceDiscrepancy{
+				UserID:        user.ID,
+				Username:      user.Username,
+				Coins:         user.Coins,
+				LedgerBalance: balance,
+			})
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// postEntries is the in-memory counterpart of the package-level postEntries;
+// the caller must hold s.mu.
+func (s *MemoryStore) postEntries(kind, reference, from, to string, amount int) {
+	now := time.Now()
+	next := uint(len(s.ledger))
+	s.ledger = append(s.ledger,
+		models.LedgerEntry{ID: next + 1, Account: from, Counterparty: to, Amount: -amount, Kind: kind, Reference: reference, CreatedAt: now},
+		models.LedgerEntry{ID: next + 2, Account: to, Counterparty: from, Amount: amount, Kind: kind, Reference: reference, CreatedAt: now},
+	)
+}
+
+func (s *MemoryStore) CreateRefreshToken(token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refreshTokens[token.TokenHash]; ok {
+		return fmt.Errorf("refresh token already exists")
+	}
+
+	s.nextRefreshTokenID++
+	token.ID = s.nextRefreshTokenID
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	copied := *token
+	s.refreshTokens[token.TokenHash] = &copied
+
+	return nil
+}
+
+func (s *MemoryStore) UseRefreshToken(ctx context.Context, tokenHash string, now time.Time) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok || token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+	s.revokeRefreshToken(token, now)
+
+	copied := *token
+	return &copied, nil
+}
+
+func (s *MemoryStore) RevokeRefreshToken(ctx context.Context, tokenHash string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if token, ok := s.refreshTokens[tokenHash]; ok && token.RevokedAt == nil {
+		s.revokeRefreshToken(token, now)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) RevokeUserSessions(ctx context.Context, userID uint, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			s.revokeRefreshToken(token, now)
+		}
+	}
+
+	return nil
+}
+
+// revokeRefreshToken marks the token as used and revokes the access token
+// issued with it; the caller must hold s.mu.
+func (s *MemoryStore) revokeRefreshToken(token *models.RefreshToken, now time.Time) {
+	token.RevokedAt = &now
+	if token.AccessTokenExpiresAt.After(now) {
+		s.revokedTokens[token.AccessTokenID] = token.AccessTokenExpiresAt
+	}
+}
+
+func (s *MemoryStore) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revokedTokens[tokenID]; !ok {
+		s.revokedTokens[tokenID] = expiresAt
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) IsTokenRevoked(tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revokedTokens[tokenID]
+	return ok, nil
+}
+
+func (s *MemoryStore) PruneExpiredTokens(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for tokenID, expiresAt := range s.revokedTokens {
+		if !expiresAt.After(now) {
+			delete(s.revokedTokens, tokenID)
+		}
+	}
+	for tokenHash, token := range s.refreshTokens {
+		if !token.ExpiresAt.After(now) {
+			delete(s.refreshTokens, tokenHash)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) GetItemPrice(productName string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productName]
+	if !ok {
+		return 0, ErrProductNotFound
+	}
+
+	return product.Price, nil
+}
+
+func (s *MemoryStore) ListProducts() ([]models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	products := make([]models.Product, 0, len(s.products))
+	for _, product := range s.products {
+		products = append(products, *product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+
+	return products, nil
+}
+
+func (s *MemoryStore) UpsertProduct(name string, price int) (*models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product := models.Product{Name: name, Price: price}
+	s.products[name] = &product
+
+	copied := product
+	return &copied, nil
+}
+
+func (s *MemoryStore) ArchiveProduct(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[name]
+	if !ok {
+		return ErrProductNotFound
+	}
+	product.Archived = true
+
+	return nil
+}
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"TestAvito/internal/models"
+	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepo struct {
@@ -19,9 +21,50 @@ func (s *ProductRepo) GetItemPrice(productName string) (int, error) {
 	var product models.Product
 
 	err := s.db.Where("name = ?", productName).First(&product).Error
-	if err != nil {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrProductNotFound
+	} else if err != nil {
 		return 0, err
 	}
 
 	return product.Price, nil
 }
+
+func (s *ProductRepo) ListProducts() ([]models.Product, error) {
+	var products []models.Product
+
+	err := s.db.Order("name").Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// UpsertProduct creates the product or updates its price. An archived product
+// is put back on sale.
+func (s *ProductRepo) UpsertProduct(name string, price int) (*models.Product, error) {
+	product := models.Product{Name: name, Price: price}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "archived"}),
+	}).Create(&product).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (s *ProductRepo) ArchiveProduct(name string) error {
+	result := s.db.Model(&models.Product{}).Where("name = ?", name).Update("archived", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProductNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"TestAvito/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+)
+
+type PurchaseRepo struct {
+	db *gorm.DB
+}
+
+func NewPurchaseRepo(db *gorm.DB) *PurchaseRepo {
+	return &PurchaseRepo{
+		db: db,
+	}
+}
+
+// Purchase charges the user for quantity items, adds them to the inventory and
+// records the purchase in a single database transaction. The user row is locked
+// for the duration so parallel purchases cannot overdraw the balance.
+func (s *PurchaseRepo) Purchase(ctx context.Context, userID uint, itemType string, quantity int) (*models.Purchase, *models.Inventory, error) {
+	if quantity <= 0 {
+		return nil, nil, ErrInvalidQuantity
+	}
+
+	var purchase models.Purchase
+	var inventory models.Inventory
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+
+		var product models.Product
+		err = tx.Where("name = ?", itemType).First(&product).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		} else if err != nil {
+			return err
+		}
+		if product.Archived {
+			return ErrProductArchived
+		}
+
+		amount, err := purchaseAmount(product.Price, quantity)
+		if err != nil {
+			return err
+		}
+		if user.Coins < amount {
+			return ErrInsufficientFunds
+		}
+
+		err = tx.Model(&user).Update("coins", gorm.Expr("coins - ?", amount)).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "item_type"}},
+			DoUpdates: clause.Set{{
+				Column: clause.Column{Name: "quantity"},
+				Value:  gorm.Expr("? + EXCLUDED.quantity", clause.Column{Table: clause.CurrentTable, Name: "quantity"}),
+			}},
+		}).Create(&models.Inventory{
+			UserID:   user.ID,
+			ItemType: itemType,
+			Quantity: quantity,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("user_id = ? AND item_type = ?", user.ID, itemType).First(&inventory).Error
+		if err != nil {
+			return err
+		}
+
+		purchase = models.Purchase{
+			UserID:   user.ID,
+			ItemType: itemType,
+			Quantity: quantity,
+			Price:    product.Price,
+			Amount:   amount,
+		}
+		err = tx.Create(&purchase).Error
+		if err != nil {
+			return err
+		}
+
+		return postEntries(tx, models.LedgerKindPurchase, fmt.Sprintf("purchase:%d", purchase.ID),
+			models.UserAccount(user.ID), models.AccountStore, amount)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &purchase, &inventory, nil
+}
+
+// purchaseAmount is the cost of quantity items at price. A product whose total
+// does not fit into an int is rejected instead of wrapping around to a
+// negative charge, which would credit the buyer.
+func purchaseAmount(price, quantity int) (int, error) {
+	if price > 0 && quantity > math.MaxInt/price {
+		return 0, ErrInvalidQuantity
+	}
+	amount := price * quantity
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	return amount, nil
+}
@@ -2,18 +2,20 @@ package storage
 
 import (
 	"TestAvito/internal/models"
+	"context"
 	"gorm.io/gorm"
+	"time"
 )
 
 type UserStorage interface {
 	CreateUser(username, password string) (*models.User, error)
 	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(id uint) (*models.User, error)
 	UpdateUser(updatedUser *models.User) (*models.User, error)
-	UpdateTwoUsers(updatedUser1 *models.User, updatedUser2 *models.User) (*models.User, *models.User, error)
 }
 
 type TransactionStorage interface {
-	CreateTransaction(fromUserID, toUserID uint, amount int) (*models.Transaction, error)
+	Transfer(ctx context.Context, fromUsername, toUsername string, amount int) (*models.Transaction, error)
 	GetGiftsGivenByUser(userID uint) ([]models.TransactionsFromUser, error)
 	GetGiftsGivenToUser(userID uint) ([]models.TransactionsToUser, error)
 }
@@ -24,14 +26,39 @@ type InventoryStorage interface {
 	GetPurchasedItems(userID uint) ([]models.Inventory, error)
 }
 
+type PurchaseStorage interface {
+	Purchase(ctx context.Context, userID uint, itemType string, quantity int) (*models.Purchase, *models.Inventory, error)
+}
+
+type LedgerStorage interface {
+	GetLedgerEntries(account string) ([]models.LedgerEntry, error)
+	Reconcile(ctx context.Context) ([]models.BalanceDiscrepancy, error)
+}
+
+type TokenStorage interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	UseRefreshToken(ctx context.Context, tokenHash string, now time.Time) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string, now time.Time) error
+	RevokeUserSessions(ctx context.Context, userID uint, now time.Time) error
+	RevokeAccessToken(tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(tokenID string) (bool, error)
+	PruneExpiredTokens(ctx context.Context, now time.Time) error
+}
+
 type ProductStorage interface {
 	GetItemPrice(productName string) (int, error)
+	ListProducts() ([]models.Product, error)
+	UpsertProduct(name string, price int) (*models.Product, error)
+	ArchiveProduct(name string) error
 }
 
 type Storage struct {
 	UserStorage
 	TransactionStorage
 	InventoryStorage
+	PurchaseStorage
+	LedgerStorage
+	TokenStorage
 	ProductStorage
 }
 
@@ -40,6 +67,9 @@ func New(db *gorm.DB) *Storage {
 		UserStorage:        NewUserRepo(db),
 		TransactionStorage: NewTransactionRepo(db),
 		InventoryStorage:   NewInventoryRepo(db),
+		PurchaseStorage:    NewPurchaseRepo(db),
+		LedgerStorage:      NewLedgerRepo(db),
+		TokenStorage:       NewTokenRepo(db),
 		ProductStorage:     NewProductRepo(db),
 	}
 }
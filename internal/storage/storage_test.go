@@ -2,24 +2,33 @@ package storage
 
 import (
 	"TestAvito/internal/models"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/exp/slog"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"sync"
 	"testing"
+	"time"
 )
 
+// testDSN is the database started by docker-compose.test.yml.
+const testDSN = "host=localhost user=postgres password=password dbname=testdb port=5433 sslmode=disable"
+
 func getTestDB(t *testing.T) *gorm.DB {
-	dsn := "host=localhost user=postgres password=password dbname=testdb port=5433 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(testDSN), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
 	return db
 }
 
+// applyMigrations builds the schema for the repository tests below, which
+// insert rows without their users and products and therefore cannot run
+// against the foreign keys of the real migrations; the conformance suite does.
 func applyMigrations(db *gorm.DB) {
-	err := db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.Inventory{}, &models.Product{})
+	err := db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.Inventory{}, &models.Product{}, &models.Purchase{}, &models.LedgerEntry{},
+		&models.RefreshToken{}, &models.RevokedToken{})
 	if err != nil {
 		slog.Info("Failed to apply migrations: %v", err)
 	}
@@ -30,6 +39,10 @@ func clearTables(db *gorm.DB) {
 	db.Exec("TRUNCATE TABLE transactions CASCADE")
 	db.Exec("TRUNCATE TABLE inventories CASCADE")
 	db.Exec("TRUNCATE TABLE products CASCADE")
+	db.Exec("TRUNCATE TABLE purchases CASCADE")
+	db.Exec("TRUNCATE TABLE ledger_entries CASCADE")
+	db.Exec("TRUNCATE TABLE refresh_tokens CASCADE")
+	db.Exec("TRUNCATE TABLE revoked_tokens CASCADE")
 }
 
 func TestInventoryRepo_CreateInventory(t *testing.T) {
@@ -72,6 +85,41 @@ func TestInventoryRepo_GetPurchasedItems(t *testing.T) {
 	assert.Len(t, items, 2)
 }
 
+func TestLedgerRepo_Reconcile(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	users := NewUserRepo(db)
+	buyer, _ := users.CreateUser("buyer", "password1")
+	_, _ = users.CreateUser("recipient", "password2")
+	_ = db.Create(&models.Product{Name: "cup", Price: 20})
+
+	_, err := NewTransactionRepo(db).Transfer(context.Background(), "buyer", "recipient", 100)
+	assert.NoError(t, err)
+	_, _, err = NewPurchaseRepo(db).Purchase(context.Background(), buyer.ID, "cup", 2)
+	assert.NoError(t, err)
+
+	repo := NewLedgerRepo(db)
+	entries, err := repo.GetLedgerEntries(models.UserAccount(buyer.ID))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, models.LedgerKindPurchase, entries[2].Kind)
+	assert.Equal(t, -40, entries[2].Amount)
+	assert.Equal(t, models.AccountStore, entries[2].Counterparty)
+
+	discrepancies, err := repo.Reconcile(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, discrepancies)
+
+	db.Model(&models.User{}).Where("id = ?", buyer.ID).Update("coins", 5000)
+	discrepancies, err = repo.Reconcile(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, discrepancies, 1)
+	assert.Equal(t, buyer.ID, discrepancies[0].UserID)
+	assert.Equal(t, 860, discrepancies[0].LedgerBalance)
+}
+
 func TestProductRepo_GetItemPrice(t *testing.T) {
 	db := getTestDB(t)
 	defer clearTables(db)
@@ -96,13 +144,218 @@ func TestProductRepo_GetItemPrice_NotFound(t *testing.T) {
 	assert.Equal(t, 0, price)
 }
 
+func TestPurchaseRepo_Purchase(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	user, _ := NewUserRepo(db).CreateUser("buyer", "password")
+	_ = db.Create(&models.Product{Name: "cup", Price: 20})
+
+	repo := NewPurchaseRepo(db)
+	purchase, inventory, err := repo.Purchase(context.Background(), user.ID, "cup", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, purchase.Price)
+	assert.Equal(t, 40, purchase.Amount)
+	assert.Equal(t, 2, inventory.Quantity)
+
+	_, inventory, err = repo.Purchase(context.Background(), user.ID, "cup", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, inventory.Quantity)
+
+	user, _ = NewUserRepo(db).GetUserByUsername("buyer")
+	assert.Equal(t, 900, user.Coins)
+}
+
+func TestPurchaseRepo_Purchase_InsufficientFunds(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	user, _ := NewUserRepo(db).CreateUser("buyer", "password")
+	_ = db.Create(&models.Product{Name: "pink-hoody", Price: 500})
+
+	repo := NewPurchaseRepo(db)
+	_, _, err := repo.Purchase(context.Background(), user.ID, "pink-hoody", 3)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	items, _ := NewInventoryRepo(db).GetPurchasedItems(user.ID)
+	assert.Len(t, items, 0)
+}
+
+func TestPurchaseRepo_Purchase_ProductNotFound(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	user, _ := NewUserRepo(db).CreateUser("buyer", "password")
+
+	repo := NewPurchaseRepo(db)
+	_, _, err := repo.Purchase(context.Background(), user.ID, "nonexistent_product", 1)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestProductRepo_UpsertProduct(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	repo := NewProductRepo(db)
+	product, err := repo.UpsertProduct("sticker", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, product.Price)
+
+	_, err = repo.UpsertProduct("sticker", 7)
+	assert.NoError(t, err)
+	price, err := repo.GetItemPrice("sticker")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, price)
+}
+
+func TestProductRepo_ArchiveProduct(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	repo := NewProductRepo(db)
+	_, _ = repo.UpsertProduct("cup", 20)
+	_, _ = repo.UpsertProduct("pen", 10)
+
+	err := repo.ArchiveProduct("cup")
+	assert.NoError(t, err)
+
+	products, err := repo.ListProducts()
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "cup", products[0].Name)
+	assert.True(t, products[0].Archived)
+	assert.False(t, products[1].Archived)
+
+	user, _ := NewUserRepo(db).CreateUser("buyer", "password")
+	_, _, err = NewPurchaseRepo(db).Purchase(context.Background(), user.ID, "cup", 1)
+	assert.ErrorIs(t, err, ErrProductArchived)
+
+	_, err = repo.UpsertProduct("cup", 25)
+	assert.NoError(t, err)
+	_, _, err = NewPurchaseRepo(db).Purchase(context.Background(), user.ID, "cup", 1)
+	assert.NoError(t, err)
+}
+
+func TestProductRepo_ArchiveProduct_NotFound(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	repo := NewProductRepo(db)
+	err := repo.ArchiveProduct("nonexistent_product")
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestTokenRepo_UseRefreshToken(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	user, _ := NewUserRepo(db).CreateUser("testuser", "password123")
+
+	repo := NewTokenRepo(db)
+	err := repo.CreateRefreshToken(&models.RefreshToken{
+		UserID:               user.ID,
+		TokenHash:            "hash",
+		AccessTokenID:        "access-1",
+		AccessTokenExpiresAt: time.Now().Add(time.Minute),
+		ExpiresAt:            time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	token, err := repo.UseRefreshToken(context.Background(), "hash", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, token.UserID)
+
+	revoked, err := repo.IsTokenRevoked("access-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = repo.UseRefreshToken(context.Background(), "hash", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestTokenRepo_UseRefreshToken_Expired(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	user, _ := NewUserRepo(db).CreateUser("testuser", "password123")
+
+	repo := NewTokenRepo(db)
+	_ = repo.CreateRefreshToken(&models.RefreshToken{
+		UserID:               user.ID,
+		TokenHash:            "hash",
+		AccessTokenID:        "access-1",
+		AccessTokenExpiresAt: time.Now().Add(-time.Hour),
+		ExpiresAt:            time.Now().Add(-time.Minute),
+	})
+
+	_, err := repo.UseRefreshToken(context.Background(), "hash", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestTokenRepo_RevokeUserSessions(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	user, _ := NewUserRepo(db).CreateUser("testuser", "password123")
+
+	repo := NewTokenRepo(db)
+	for _, id := range []string{"access-1", "access-2"} {
+		_ = repo.CreateRefreshToken(&models.RefreshToken{
+			UserID:               user.ID,
+			TokenHash:            "hash-" + id,
+			AccessTokenID:        id,
+			AccessTokenExpiresAt: time.Now().Add(time.Minute),
+			ExpiresAt:            time.Now().Add(time.Hour),
+		})
+	}
+
+	err := repo.RevokeUserSessions(context.Background(), user.ID, time.Now())
+	assert.NoError(t, err)
+
+	for _, id := range []string{"access-1", "access-2"} {
+		revoked, err := repo.IsTokenRevoked(id)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		_, err = repo.UseRefreshToken(context.Background(), "hash-"+id, time.Now())
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestTokenRepo_RevokeAccessToken(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	repo := NewTokenRepo(db)
+	revoked, err := repo.IsTokenRevoked("access-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, repo.RevokeAccessToken("access-1", time.Now().Add(time.Minute)))
+	assert.NoError(t, repo.RevokeAccessToken("access-1", time.Now().Add(time.Minute)))
+
+	revoked, err = repo.IsTokenRevoked("access-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
 func TestTransactionRepo_CreateTransaction(t *testing.T) {
 	db := getTestDB(t)
 	defer clearTables(db)
 	applyMigrations(db)
 
 	repo := NewTransactionRepo(db)
-	transaction, err := repo.CreateTransaction(1, 2, 50)
+	transaction, err := repo.createTransaction(1, 2, 50)
 	assert.NoError(t, err)
 	assert.NotNil(t, transaction)
 	assert.Equal(t, uint(1), transaction.FromUserID)
@@ -110,19 +363,123 @@ func TestTransactionRepo_CreateTransaction(t *testing.T) {
 	assert.Equal(t, 50, transaction.Amount)
 }
 
+func TestTransactionRepo_Transfer(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	users := NewUserRepo(db)
+	sender, _ := users.CreateUser("sender", "password1")
+	recipient, _ := users.CreateUser("recipient", "password2")
+
+	repo := NewTransactionRepo(db)
+	transaction, err := repo.Transfer(context.Background(), "sender", "recipient", 300)
+	assert.NoError(t, err)
+	assert.NotNil(t, transaction)
+	assert.Equal(t, sender.ID, transaction.FromUserID)
+	assert.Equal(t, recipient.ID, transaction.ToUserID)
+	assert.Equal(t, 300, transaction.Amount)
+
+	sender, _ = users.GetUserByUsername("sender")
+	recipient, _ = users.GetUserByUsername("recipient")
+	assert.Equal(t, 700, sender.Coins)
+	assert.Equal(t, 1300, recipient.Coins)
+}
+
+func TestTransactionRepo_Transfer_InsufficientFunds(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	users := NewUserRepo(db)
+	_, _ = users.CreateUser("sender", "password1")
+	_, _ = users.CreateUser("recipient", "password2")
+
+	repo := NewTransactionRepo(db)
+	transaction, err := repo.Transfer(context.Background(), "sender", "recipient", 1001)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	assert.Nil(t, transaction)
+
+	sender, _ := users.GetUserByUsername("sender")
+	assert.Equal(t, 1000, sender.Coins)
+
+	var count int64
+	db.Model(&models.Transaction{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestTransactionRepo_Transfer_SelfTransfer(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	users := NewUserRepo(db)
+	_, _ = users.CreateUser("sender", "password1")
+
+	repo := NewTransactionRepo(db)
+	_, err := repo.Transfer(context.Background(), "sender", "sender", 10)
+	assert.ErrorIs(t, err, ErrSelfTransfer)
+}
+
+func TestTransactionRepo_Transfer_RecipientNotFound(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	users := NewUserRepo(db)
+	_, _ = users.CreateUser("sender", "password1")
+
+	repo := NewTransactionRepo(db)
+	_, err := repo.Transfer(context.Background(), "sender", "nobody", 10)
+	assert.ErrorIs(t, err, ErrRecipientNotFound)
+}
+
+func TestTransactionRepo_Transfer_Concurrent(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	users := NewUserRepo(db)
+	_, _ = users.CreateUser("sender", "password1")
+	_, _ = users.CreateUser("recipient", "password2")
+
+	repo := NewTransactionRepo(db)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = repo.Transfer(context.Background(), "sender", "recipient", 100)
+		}()
+	}
+	wg.Wait()
+
+	sender, _ := users.GetUserByUsername("sender")
+	recipient, _ := users.GetUserByUsername("recipient")
+	assert.Equal(t, 0, sender.Coins)
+	assert.Equal(t, 2000, recipient.Coins)
+}
+
 func TestTransactionRepo_GetGiftsGivenByUser(t *testing.T) {
 	db := getTestDB(t)
 	defer clearTables(db)
 	applyMigrations(db)
 
+	users := NewUserRepo(db)
+	alice, _ := users.CreateUser("alice", "password1")
+	bob, _ := users.CreateUser("bob", "password2")
+	carol, _ := users.CreateUser("carol", "password3")
+
 	repo := NewTransactionRepo(db)
-	_ = db.Create(&models.Transaction{FromUserID: 1, ToUserID: 2, Amount: 50})
-	_ = db.Create(&models.Transaction{FromUserID: 1, ToUserID: 3, Amount: 30})
-	gifts, err := repo.GetGiftsGivenByUser(1)
+	_ = db.Create(&models.Transaction{FromUserID: alice.ID, ToUserID: bob.ID, Amount: 50})
+	_ = db.Create(&models.Transaction{FromUserID: alice.ID, ToUserID: bob.ID, Amount: 20})
+	_ = db.Create(&models.Transaction{FromUserID: alice.ID, ToUserID: carol.ID, Amount: 30})
+	gifts, err := repo.GetGiftsGivenByUser(alice.ID)
 	assert.NoError(t, err)
 	assert.Len(t, gifts, 2)
-	assert.Equal(t, string("2"), gifts[0].ToUser)
-	assert.Equal(t, 50, gifts[0].Amount)
+	assert.Equal(t, "bob", gifts[0].ToUser)
+	assert.Equal(t, 70, gifts[0].Amount)
+	assert.Equal(t, "carol", gifts[1].ToUser)
 }
 
 func TestTransactionRepo_GetGiftsGivenToUser(t *testing.T) {
@@ -130,13 +487,18 @@ func TestTransactionRepo_GetGiftsGivenToUser(t *testing.T) {
 	defer clearTables(db)
 	applyMigrations(db)
 
+	users := NewUserRepo(db)
+	alice, _ := users.CreateUser("alice", "password1")
+	bob, _ := users.CreateUser("bob", "password2")
+	carol, _ := users.CreateUser("carol", "password3")
+
 	repo := NewTransactionRepo(db)
-	_ = db.Create(&models.Transaction{FromUserID: 2, ToUserID: 1, Amount: 50})
-	_ = db.Create(&models.Transaction{FromUserID: 3, ToUserID: 1, Amount: 30})
-	gifts, err := repo.GetGiftsGivenToUser(1)
+	_ = db.Create(&models.Transaction{FromUserID: bob.ID, ToUserID: alice.ID, Amount: 50})
+	_ = db.Create(&models.Transaction{FromUserID: carol.ID, ToUserID: alice.ID, Amount: 30})
+	gifts, err := repo.GetGiftsGivenToUser(alice.ID)
 	assert.NoError(t, err)
 	assert.Len(t, gifts, 2)
-	assert.Equal(t, string("2"), gifts[0].FromUser)
+	assert.Equal(t, "bob", gifts[0].FromUser)
 	assert.Equal(t, 50, gifts[0].Amount)
 }
 
@@ -169,6 +531,31 @@ func TestUserRepo_GetUserByUsername(t *testing.T) {
 	assert.Equal(t, "password123", user.Password)
 }
 
+func TestUserRepo_CreateUser_Duplicate(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	repo := NewUserRepo(db)
+	_, err := repo.CreateUser("testuser", "password123")
+	assert.NoError(t, err)
+
+	user, err := repo.CreateUser("testuser", "password456")
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.Nil(t, user)
+}
+
+func TestUserRepo_GetUserByUsername_NotFound(t *testing.T) {
+	db := getTestDB(t)
+	defer clearTables(db)
+	applyMigrations(db)
+
+	repo := NewUserRepo(db)
+	user, err := repo.GetUserByUsername("nobody")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.Nil(t, user)
+}
+
 func TestUserRepo_UpdateUser(t *testing.T) {
 	db := getTestDB(t)
 	defer clearTables(db)
@@ -195,7 +582,7 @@ func TestUserRepo_UpdateTwoUsers(t *testing.T) {
 
 	user1.Username = "updateduser1"
 	user2.Username = "updateduser2"
-	updatedUser1, updatedUser2, err := repo.UpdateTwoUsers(user1, user2)
+	updatedUser1, updatedUser2, err := repo.updateTwoUsers(user1, user2)
 	assert.NoError(t, err)
 	assert.NotNil(t, updatedUser1)
 	assert.NotNil(t, updatedUser2)
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"TestAvito/internal/models"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type TokenRepo struct {
+	db *gorm.DB
+}
+
+func NewTokenRepo(db *gorm.DB) *TokenRepo {
+	return &TokenRepo{
+		db: db,
+	}
+}
+
+func (s *TokenRepo) CreateRefreshToken(token *models.RefreshToken) error {
+	return s.db.Create(token).Error
+}
+
+// UseRefreshToken consumes a refresh token: it is revoked together with the
+// access token issued alongside it, and returned so that the caller can issue a
+// new pair. Expired, revoked and unknown tokens yield ErrInvalidRefreshToken.
+// now is the caller's clock, the same one that issued the tokens.
+func (s *TokenRepo) UseRefreshToken(ctx context.Context, tokenHash string, now time.Time) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", tokenHash, now).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		} else if err != nil {
+			return err
+		}
+
+		return revokeRefreshTokens(tx, []models.RefreshToken{token}, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *TokenRepo) RevokeRefreshToken(ctx context.Context, tokenHash string, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tokens []models.RefreshToken
+		err := tx.Where("token_hash = ? AND revoked_at IS NULL", tokenHash).Find(&tokens).Error
+		if err != nil {
+			return err
+		}
+
+		return revokeRefreshTokens(tx, tokens, now)
+	})
+}
+
+// RevokeUserSessions signs the user out everywhere by revoking all of their
+// refresh tokens and the access tokens issued with them.
+func (s *TokenRepo) RevokeUserSessions(ctx context.Context, userID uint, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tokens []models.RefreshToken
+		err := tx.Where("user_id = ? AND revoked_at IS NULL", userID).Find(&tokens).Error
+		if err != nil {
+			return err
+		}
+
+		return revokeRefreshTokens(tx, tokens, now)
+	})
+}
+
+func (s *TokenRepo) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt}).Error
+}
+
+func (s *TokenRepo) IsTokenRevoked(tokenID string) (bool, error) {
+	var count int64
+
+	err := s.db.Model(&models.RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// PruneExpiredTokens deletes refresh tokens and revoked access token IDs that
+// have expired by now: neither can be used any more.
+func (s *TokenRepo) PruneExpiredTokens(ctx context.Context, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("expires_at <= ?", now).Delete(&models.RefreshToken{}).Error
+	})
+}
+
+func revokeRefreshTokens(tx *gorm.DB, tokens []models.RefreshToken, now time.Time) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(tokens))
+	revoked := make([]models.RevokedToken, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.ID)
+		if token.AccessTokenExpiresAt.After(now) {
+			revoked = append(revoked, models.RevokedToken{TokenID: token.AccessTokenID, ExpiresAt: token.AccessTokenExpiresAt})
+		}
+	}
+
+	err := tx.Model(&models.RefreshToken{}).Where("id IN ?", ids).Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	if len(revoked) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"TestAvito/internal/models"
+	"context"
+	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepo struct {
@@ -15,7 +18,9 @@ func NewTransactionRepo(db *gorm.DB) *TransactionRepo {
 	}
 }
 
-func (s *TransactionRepo) CreateTransaction(fromUserID, toUserID uint, amount int) (*models.Transaction, error) {
+// createTransaction inserts a bare transaction row without moving coins or
+// posting ledger entries. Only tests use it; transfers go through Transfer.
+func (s *TransactionRepo) createTransaction(fromUserID, toUserID uint, amount int) (*models.Transaction, error) {
 	transaction := models.Transaction{
 		FromUserID: fromUserID,
 		ToUserID:   toUserID,
@@ -28,13 +33,86 @@ func (s *TransactionRepo) CreateTransaction(fromUserID, toUserID uint, amount in
 	return &transaction, nil
 }
 
+// Transfer moves coins between two users and records the transaction in a single
+// database transaction. Both rows are locked in ID order so that concurrent
+// transfers between the same pair of users cannot deadlock.
+func (s *TransactionRepo) Transfer(ctx context.Context, fromUsername, toUsername string, amount int) (*models.Transaction, error) {
+	if fromUsername == toUsername {
+		return nil, ErrSelfTransfer
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var transaction models.Transaction
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var users []models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("username IN ?", []string{fromUsername, toUsername}).
+			Order("id").
+			Find(&users).Error
+		if err != nil {
+			return err
+		}
+
+		var sender, recipient *models.User
+		for i := range users {
+			switch users[i].Username {
+			case fromUsername:
+				sender = &users[i]
+			case toUsername:
+				recipient = &users[i]
+			}
+		}
+		if sender == nil {
+			return ErrUserNotFound
+		}
+		if recipient == nil {
+			return ErrRecipientNotFound
+		}
+		if sender.Coins < amount {
+			return ErrInsufficientFunds
+		}
+
+		err = tx.Model(sender).Update("coins", gorm.Expr("coins - ?", amount)).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(recipient).Update("coins", gorm.Expr("coins + ?", amount)).Error
+		if err != nil {
+			return err
+		}
+
+		transaction = models.Transaction{
+			FromUserID: sender.ID,
+			ToUserID:   recipient.ID,
+			Amount:     amount,
+		}
+		err = tx.Create(&transaction).Error
+		if err != nil {
+			return err
+		}
+
+		return postEntries(tx, models.LedgerKindTransfer, fmt.Sprintf("transaction:%d", transaction.ID),
+			models.UserAccount(sender.ID), models.UserAccount(recipient.ID), amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
 func (s *TransactionRepo) GetGiftsGivenByUser(userID uint) ([]models.TransactionsFromUser, error) {
 	var result []models.TransactionsFromUser
 
 	err := s.db.Table("transactions").
-		Select("to_user_id AS to_user, SUM(amount) AS amount").
-		Where("from_user_id = ?", userID).
-		Group("to_user_id").
+		Select("users.username AS to_user, SUM(transactions.amount) AS amount").
+		Joins("JOIN users ON users.id = transactions.to_user_id").
+		Where("transactions.from_user_id = ?", userID).
+		Group("users.username").
+		Order("users.username").
 		Scan(&result).Error
 	if err != nil {
 		return nil, err
@@ -47,9 +125,11 @@ func (s *TransactionRepo) GetGiftsGivenToUser(userID uint) ([]models.Transaction
 	var result []models.TransactionsToUser
 
 	err := s.db.Table("transactions").
-		Select("from_user_id AS from_user, SUM(amount) AS amount").
-		Where("to_user_id = ?", userID).
-		Group("from_user_id").
+		Select("users.username AS from_user, SUM(transactions.amount) AS amount").
+		Joins("JOIN users ON users.id = transactions.from_user_id").
+		Where("transactions.to_user_id = ?", userID).
+		Group("users.username").
+		Order("users.username").
 		Scan(&result).Error
 	if err != nil {
 		return nil, err
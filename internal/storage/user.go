@@ -2,9 +2,13 @@ package storage
 
 import (
 	"TestAvito/internal/models"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+const uniqueViolation = "23505"
+
 type UserRepo struct {
 	db *gorm.DB
 }
@@ -17,7 +21,18 @@ func NewUserRepo(db *gorm.DB) *UserRepo {
 
 func (s *UserRepo) CreateUser(username, password string) (*models.User, error) {
 	user := models.User{Username: username, Password: password}
-	if err := s.db.Create(&user).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&user).Error
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return ErrUserExists
+		} else if err != nil {
+			return err
+		}
+		return postEntries(tx, models.LedgerKindGrant, "signup",
+			models.AccountIssuer, models.UserAccount(user.ID), user.Coins)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -27,22 +42,41 @@ func (s *UserRepo) GetUserByUsername(username string) (*models.User, error) {
 	var user models.User
 
 	err := s.db.Where("username = ?", username).First(&user).Error
-	if err != nil {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *UserRepo) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+
+	err := s.db.First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
+// UpdateUser and updateTwoUsers never touch coins: balances only change through
+// operations that post matching ledger entries.
 func (s *UserRepo) UpdateUser(updatedUser *models.User) (*models.User, error) {
 	var user models.User
 
 	err := s.db.First(&user, updatedUser.ID).Error
-	if err != nil {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
 		return nil, err
 	}
 
-	err = s.db.Model(&user).Updates(updatedUser).Error
+	err = s.db.Model(&user).Omit("coins").Updates(updatedUser).Error
 	if err != nil {
 		return nil, err
 	}
@@ -50,18 +84,20 @@ func (s *UserRepo) UpdateUser(updatedUser *models.User) (*models.User, error) {
 	return &user, nil
 }
 
-func (s *UserRepo) UpdateTwoUsers(updatedUser1 *models.User, updatedUser2 *models.User) (*models.User, *models.User, error) {
+// updateTwoUsers is kept for the repository tests; nothing else updates two
+// users at once.
+func (s *UserRepo) updateTwoUsers(updatedUser1 *models.User, updatedUser2 *models.User) (*models.User, *models.User, error) {
 	tx := s.db.Begin()
 
 	var user1 models.User
-	err := tx.Model(&user1).Where("id = ?", updatedUser1.ID).Updates(updatedUser1).Error
+	err := tx.Model(&user1).Where("id = ?", updatedUser1.ID).Omit("coins").Updates(updatedUser1).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, nil, err
 	}
 
 	var user2 models.User
-	err = tx.Model(&user2).Where("id = ?", updatedUser2.ID).Updates(updatedUser2).Error
+	err = tx.Model(&user2).Where("id = ?", updatedUser2.ID).Omit("coins").Updates(updatedUser2).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, nil, err
@@ -2,161 +2,214 @@ package e2e
 
 import (
 	"TestAvito/internal/models"
-	"encoding/json"
-	"github.com/labstack/echo"
+	"context"
 	"net/http"
-	"net/http/httptest"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-type Response struct {
-	User      models.User      `json:"user"`
-	ItemPrice int              `json:"item_price"`
-	Inventory models.Inventory `json:"inventory"`
+const password = "secret123"
+
+func TestRegisterSendBuyInfo(t *testing.T) {
+	h := newMemoryHarness(t)
+
+	h.register("alice", password)
+	h.register("bob", password)
+	alice := h.auth("alice", password).Token
+	bob := h.auth("bob", password).Token
+
+	resp := h.sendCoin(alice, "bob", 200)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Body))
+	var sent models.SendCoinResponse
+	resp.decode(t, &sent)
+	assert.Equal(t, models.SendCoinResponse{ToUser: "bob", Amount: 200, Coins: 800}, sent)
+
+	resp = h.buy(bob, "cup", 2)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Body))
+	var bought models.BuyItemResponse
+	resp.decode(t, &bought)
+	assert.Equal(t, models.BuyItemResponse{Item: "cup", Quantity: 2, Price: 20, Total: 40, Coins: 1160, Owned: 2}, bought)
+
+	resp = h.buy(bob, "pink-hoody", 1)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Body))
+	resp = h.buy(alice, "pen", 1)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Body))
+
+	assert.Equal(t, models.InfoResponse{
+		Coins: 660,
+		Inventory: []models.InventoryItem{
+			{Type: "cup", Quantity: 2},
+			{Type: "pink-hoody", Quantity: 1},
+		},
+		CoinHistory: models.CoinHistory{
+			Received: []models.TransactionsToUser{{FromUser: "alice", Amount: 200}},
+			Sent:     []models.TransactionsFromUser{},
+		},
+	}, h.info(bob))
+
+	assert.Equal(t, models.InfoResponse{
+		Coins:     790,
+		Inventory: []models.InventoryItem{{Type: "pen", Quantity: 1}},
+		CoinHistory: models.CoinHistory{
+			Received: []models.TransactionsToUser{},
+			Sent:     []models.TransactionsFromUser{{ToUser: "bob", Amount: 200}},
+		},
+	}, h.info(alice))
+
+	discrepancies, err := h.storage.Reconcile(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, discrepancies)
 }
 
-func TestBuyItemHandler(t *testing.T) {
-	e := echo.New()
-	e.POST("/api/v1/buy/:item", func(c echo.Context) error {
-		itemName := c.Param("item")
-		if itemName == "" {
-			return c.JSON(http.StatusBadRequest, "Item name is required")
-		}
-
-		var req models.BuyItemRequest
-		if err := c.Bind(&req); err != nil {
-			return c.JSON(http.StatusBadRequest, "Bad request")
-		}
-
-		user := models.User{
-			ID:       10,
-			Username: "test15",
-			Password: "$2a$10$xBsRYLXqyMIpErS9PAU1qeqEfpqwt1oP1U.RXMtblKbzERUCKzj0q",
-			Coins:    1000,
-		}
-
-		itemPrice := 10
-		if user.Coins < itemPrice*req.Quantity {
-			return c.JSON(http.StatusBadRequest, "Not enough money")
-		}
-
-		user.Coins -= itemPrice * req.Quantity
-
-		inventory := models.Inventory{
-			UserID:   user.ID,
-			ItemType: itemName,
-			Quantity: req.Quantity,
-		}
-
-		resp := Response{
-			User:      user,
-			ItemPrice: itemPrice,
-			Inventory: inventory,
-		}
-
-		return c.JSON(http.StatusOK, resp)
-	})
-
-	ts := httptest.NewServer(e)
-	defer ts.Close()
-
-	body := `{"quantity": 2}`
-	resp, err := http.Post(ts.URL+"/api/v1/buy/cup", "application/json", strings.NewReader(body))
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
-
-	var result Response
-	json.NewDecoder(resp.Body).Decode(&result)
-	assert.Equal(t, 2, result.Inventory.Quantity)
-	assert.Equal(t, "cup", result.Inventory.ItemType)
-	assert.Equal(t, 980, result.User.Coins)
-
-	resp, err = http.Post(ts.URL+"/api/v1/buy/", "application/json", strings.NewReader(body))
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
-
-	invalidBody := `{"quantity": "two"}`
-	resp, err = http.Post(ts.URL+"/api/v1/buy/cup", "application/json", strings.NewReader(invalidBody))
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+func TestRejectedOperations(t *testing.T) {
+	h := newMemoryHarness(t)
+	h.register("alice", password)
+	h.register("bob", password)
+	alice := h.auth("alice", password).Token
+
+	resp := h.do(http.MethodPost, "/api/auth", "", models.AuthorizeUserRequest{Username: "alice", Password: "wrong-password1"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+	assert.Equal(t, "invalid_credentials", resp.errorCode(t))
+
+	resp = h.do(http.MethodPost, "/api/auth", "", models.AuthorizeUserRequest{Username: "carol", Password: password})
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+
+	resp = h.sendCoin(alice, "alice", 10)
+	assert.Equal(t, "self_transfer", resp.errorCode(t))
+	resp = h.sendCoin(alice, "carol", 10)
+	assert.Equal(t, "recipient_not_found", resp.errorCode(t))
+	resp = h.sendCoin(alice, "bob", 1001)
+	assert.Equal(t, "insufficient_funds", resp.errorCode(t))
+	resp = h.buy(alice, "sofa", 1)
+	assert.Equal(t, "product_not_found", resp.errorCode(t))
+	resp = h.buy(alice, "pink-hoody", 3)
+	assert.Equal(t, "insufficient_funds", resp.errorCode(t))
+
+	resp = h.do(http.MethodGet, "/api/info", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+
+	info := h.info(alice)
+	assert.Equal(t, 1000, info.Coins)
+	assert.Empty(t, info.Inventory)
 }
 
-func TestSendCoinHandler(t *testing.T) {
-	e := echo.New()
-	e.POST("/api/v1/send_coin", func(c echo.Context) error {
-		username := "test15"
-
-		var req models.SendCoinRequest
-		if err := c.Bind(&req); err != nil {
-			return c.JSON(http.StatusBadRequest, "Bad request")
-		}
-
-		user := models.User{
-			ID:       10,
-			Username: username,
-			Password: "$2a$10$xBsRYLXqyMIpErS9PAU1qeqEfpqwt1oP1U.RXMtblKbzERUCKzj0q",
-			Coins:    860,
-		}
-
-		recipient := models.User{
-			ID:       5,
-			Username: "test5",
-			Password: "password",
-			Coins:    1200,
-		}
-
-		if user.Coins < req.Amount {
-			return c.JSON(http.StatusBadRequest, "Not enough money")
-		}
-
-		user.Coins -= req.Amount
-		recipient.Coins += req.Amount
-
-		transaction := models.Transaction{
-			ID:         8,
-			FromUserID: user.ID,
-			ToUserID:   recipient.ID,
-			Amount:     req.Amount,
-		}
-
-		resp := map[string]interface{}{
-			"user":        user,
-			"recipient":   recipient,
-			"transaction": transaction,
-		}
-
-		return c.JSON(http.StatusOK, resp)
-	})
-
-	ts := httptest.NewServer(e)
-	defer ts.Close()
-
-	body := `{"recipient_username": "test5", "amount": 100}`
-	resp, err := http.Post(ts.URL+"/api/v1/send_coin", "application/json", strings.NewReader(body))
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
-
-	user := result["user"].(map[string]interface{})
-	assert.Equal(t, "test15", user["Username"])
-	assert.Equal(t, float64(760), user["Coins"])
-
-	recipient := result["recipient"].(map[string]interface{})
-	assert.Equal(t, "test5", recipient["Username"])
-	assert.Equal(t, float64(1300), recipient["Coins"])
-
-	body = `{"recipient_username": "test5", "amount": 10000}`
-	resp, err = http.Post(ts.URL+"/api/v1/send_coin", "application/json", strings.NewReader(body))
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
-
-	invalidBody := `{"recipient_username": "test5", "amount": "hundred"}`
-	resp, err = http.Post(ts.URL+"/api/v1/send_coin", "application/json", strings.NewReader(invalidBody))
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+func TestAccessTokenExpiresWithClock(t *testing.T) {
+	h := newMemoryHarness(t)
+	issued := h.register("alice", password)
+
+	h.info(issued.Token)
+
+	h.clock.Advance(16 * time.Minute)
+	resp := h.do(http.MethodGet, "/api/info", issued.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+
+	resp = h.do(http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: issued.RefreshToken})
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Body))
+	var refreshed tokens
+	resp.decode(t, &refreshed)
+
+	assert.Equal(t, 1000, h.info(refreshed.Token).Coins)
+
+	h.clock.Advance(2 * time.Hour)
+	resp = h.do(http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+}
+
+func TestRequestIDsComeFromGenerator(t *testing.T) {
+	h := newMemoryHarness(t)
+
+	resp := h.do(http.MethodGet, "/api/products", "", nil)
+	assert.Equal(t, "id-1", resp.Header.Get("X-Request-ID"))
+
+	resp = h.do(http.MethodGet, "/api/products", "", nil)
+	assert.Equal(t, "id-2", resp.Header.Get("X-Request-ID"))
+}
+
+func TestParallelTransfersNeverGoNegative(t *testing.T) {
+	for _, st := range storages {
+		t.Run(st.name, func(t *testing.T) {
+			h := st.newHarness(t)
+			h.register("alice", password)
+			h.register("bob", password)
+			alice := h.auth("alice", password).Token
+
+			const amount = 15
+			var succeeded, rejected atomic.Int64
+			errs := make(chan error, 100)
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					resp, err := h.send(http.MethodPost, "/api/sendCoin", alice,
+						models.SendCoinRequest{RecipientUsername: "bob", Amount: amount})
+					if err != nil {
+						errs <- err
+						return
+					}
+					switch resp.Status {
+					case http.StatusOK:
+						succeeded.Add(1)
+					case http.StatusConflict:
+						rejected.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, int64(1000/amount), succeeded.Load())
+			assert.Equal(t, int64(100-1000/amount), rejected.Load())
+
+			aliceInfo := h.info(alice)
+			assert.Equal(t, 1000%amount, aliceInfo.Coins)
+			assert.GreaterOrEqual(t, aliceInfo.Coins, 0)
+			assert.Equal(t, []models.TransactionsFromUser{{ToUser: "bob", Amount: int(succeeded.Load()) * amount}}, aliceInfo.CoinHistory.Sent)
+
+			bob := h.auth("bob", password).Token
+			assert.Equal(t, 2000-aliceInfo.Coins, h.info(bob).Coins)
+
+			discrepancies, err := h.storage.Reconcile(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, discrepancies)
+		})
+	}
+}
+
+func TestParallelPurchasesNeverGoNegative(t *testing.T) {
+	for _, st := range storages {
+		t.Run(st.name, func(t *testing.T) {
+			h := st.newHarness(t)
+			alice := h.register("alice", password).Token
+
+			errs := make(chan error, 100)
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := h.send(http.MethodGet, "/api/buy/cup?quantity=1", alice, nil); err != nil {
+						errs <- err
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			info := h.info(alice)
+			assert.Equal(t, 0, info.Coins)
+			assert.Equal(t, []models.InventoryItem{{Type: "cup", Quantity: 50}}, info.Inventory)
+		})
+	}
 }
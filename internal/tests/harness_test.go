@@ -0,0 +1,241 @@
+package e2e
+
+import (
+	"TestAvito/internal/config"
+	"TestAvito/internal/database"
+	"TestAvito/internal/models"
+	"TestAvito/internal/storage"
+	"TestAvito/internal/web"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// fakeClock is a manually advanced clock shared by the server and the test.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// sequentialIDs hands out "id-1", "id-2", ... so request and token IDs are
+// predictable.
+type sequentialIDs struct {
+	next atomic.Int64
+}
+
+func (g *sequentialIDs) NewID() string {
+	return fmt.Sprintf("id-%d", g.next.Add(1))
+}
+
+// harness runs the real web.Server over HTTP against the given storage.
+type harness struct {
+	t       *testing.T
+	server  *httptest.Server
+	storage *storage.Storage
+	clock   *fakeClock
+	ids     *sequentialIDs
+}
+
+func newHarness(t *testing.T, st *storage.Storage) *harness {
+	h := &harness{
+		t:       t,
+		storage: st,
+		clock:   newFakeClock(time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)),
+		ids:     &sequentialIDs{},
+	}
+
+	jwtCfg := config.JWT{
+		SecretKey:             "e2e-test-secret-e2e-test-secret-e2e",
+		ExpirationTime:        900,
+		RefreshExpirationTime: 3600,
+		Issuer:                "merch-store",
+		Audience:              "merch-store",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := web.New(config.Server{}, jwtCfg, config.Auth{}, logger, st,
+		web.WithClock(h.clock.Now), web.WithIDGenerator(h.ids.NewID))
+	require.NoError(t, err)
+
+	h.server = httptest.NewServer(server.Handler())
+	t.Cleanup(h.server.Close)
+
+	return h
+}
+
+func newMemoryHarness(t *testing.T) *harness {
+	return newHarness(t, storage.NewMemory())
+}
+
+// testDSN is the database started by docker-compose.test.yml.
+const testDSN = "host=localhost user=postgres password=password dbname=testdb port=5433 sslmode=disable"
+
+// newPostgresHarness runs against a fresh schema of the Postgres test
+// database built by the embedded migrations, which also add the products. The
+// test is skipped when the database is not running.
+func newPostgresHarness(t *testing.T) *harness {
+	const schema = "e2e"
+
+	open := func(dsn string) *gorm.DB {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+		if err != nil {
+			t.Skipf("postgres test database is not available: %v", err)
+		}
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+		return db
+	}
+
+	admin := open(testDSN)
+	require.NoError(t, admin.Exec("DROP SCHEMA IF EXISTS "+schema+" CASCADE").Error)
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+
+	db := open(testDSN + " search_path=" + schema)
+	require.NoError(t, database.MigrateUp(db))
+
+	return newHarness(t, storage.New(db))
+}
+
+// storages lists the harnesses that scenarios sensitive to the storage
+// implementation run against: the in-memory storage serialises everything
+// under one mutex, Postgres relies on row locks.
+var storages = []struct {
+	name       string
+	newHarness func(t *testing.T) *harness
+}{
+	{"memory", newMemoryHarness},
+	{"postgres", newPostgresHarness},
+}
+
+type response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+func (r response) decode(t *testing.T, v interface{}) {
+	require.NoError(t, json.Unmarshal(r.Body, v), string(r.Body))
+}
+
+func (r response) errorCode(t *testing.T) string {
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	r.decode(t, &body)
+	return body.Error.Code
+}
+
+// do sends a request and fails the test on transport errors. It must only be
+// called from the test goroutine; use send in goroutines.
+func (h *harness) do(method, path, token string, body interface{}) response {
+	resp, err := h.send(method, path, token, body)
+	require.NoError(h.t, err)
+	return resp
+}
+
+func (h *harness) send(method, path, token string, body interface{}) (response, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return response{}, err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, h.server.URL+path, payload)
+	if err != nil {
+		return response{}, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, err
+	}
+
+	return response{Status: resp.StatusCode, Header: resp.Header, Body: data}, nil
+}
+
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (h *harness) register(username, password string) tokens {
+	resp := h.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: username, Password: password})
+	require.Equal(h.t, http.StatusCreated, resp.Status, string(resp.Body))
+
+	var result tokens
+	resp.decode(h.t, &result)
+	return result
+}
+
+func (h *harness) auth(username, password string) tokens {
+	resp := h.do(http.MethodPost, "/api/auth", "", models.AuthorizeUserRequest{Username: username, Password: password})
+	require.Equal(h.t, http.StatusOK, resp.Status, string(resp.Body))
+
+	var result tokens
+	resp.decode(h.t, &result)
+	return result
+}
+
+func (h *harness) sendCoin(token, recipient string, amount int) response {
+	return h.do(http.MethodPost, "/api/sendCoin", token, models.SendCoinRequest{RecipientUsername: recipient, Amount: amount})
+}
+
+func (h *harness) buy(token, item string, quantity int) response {
+	return h.do(http.MethodGet, fmt.Sprintf("/api/buy/%s?quantity=%d", item, quantity), token, nil)
+}
+
+func (h *harness) info(token string) models.InfoResponse {
+	resp := h.do(http.MethodGet, "/api/info", token, nil)
+	require.Equal(h.t, http.StatusOK, resp.Status, string(resp.Body))
+
+	var result models.InfoResponse
+	resp.decode(h.t, &result)
+	return result
+}
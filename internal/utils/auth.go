@@ -1,45 +1,27 @@
 package utils
 
 import (
-	"fmt"
-	"github.com/golang-jwt/jwt/v4"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"golang.org/x/crypto/bcrypt"
-	"time"
 )
 
-type Claims struct {
-	UserName string `json:"user_name"`
-	jwt.RegisteredClaims
-}
-
-func GenerateToken(username, secretWord string) (string, error) {
-	secretKey := []byte(secretWord)
-
-	claims := Claims{
-		UserName: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// GenerateRefreshToken returns an opaque refresh token for the client and the
+// hash under which it is stored. The token itself is never persisted.
+func GenerateRefreshToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
 }
 
-func ValidateJWT(tokenString, secretWord string) (*Claims, error) {
-	secretKey := []byte(secretWord)
-
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("%+v %+v", token, claims)
-	}
-	return claims, nil
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func HashPassword(password string) (string, error) {
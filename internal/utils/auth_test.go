@@ -3,31 +3,17 @@ package utils
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
-	"time"
 )
 
-func TestGenerateToken(t *testing.T) {
-	token, err := GenerateToken("postgres", "password")
+func TestGenerateRefreshToken(t *testing.T) {
+	token, hash, err := GenerateRefreshToken()
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
+	assert.Equal(t, HashRefreshToken(token), hash)
+	assert.NotEqual(t, token, hash)
 
-	claims, err := ValidateJWT(token, "password")
-	assert.NoError(t, err)
-	assert.Equal(t, "postgres", claims.UserName)
-}
-
-func TestValidateJWT_ValidToken(t *testing.T) {
-	token, _ := GenerateToken("postgres", "password")
-	claims, err := ValidateJWT(token, "password")
-	assert.NoError(t, err)
-	assert.Equal(t, "postgres", claims.UserName)
-	assert.WithinDuration(t, time.Now(), claims.IssuedAt.Time, 10*time.Second)
-}
-
-func TestValidateJWT_InvalidSecret(t *testing.T) {
-	token, _ := GenerateToken("postgres", "password")
-	_, err := ValidateJWT(token, "wrongSecret")
-	assert.Error(t, err)
+	other, _, _ := GenerateRefreshToken()
+	assert.NotEqual(t, token, other)
 }
 
 func TestHashPassword(t *testing.T) {
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"TestAvito/internal/config"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+)
+
+const defaultTokenTTL = 15 * time.Minute
+
+var (
+	ErrTokenRevoked  = errors.New("token has been revoked")
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrInvalidClaims = errors.New("token issuer or audience mismatch")
+)
+
+type Claims struct {
+	UserName string `json:"user_name"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// RevocationList reports whether an access token was revoked before it expired.
+type RevocationList interface {
+	IsTokenRevoked(tokenID string) (bool, error)
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// TokenManager issues and validates access tokens. The signing algorithm is
+// pinned by configuration: tokens signed with any other algorithm are rejected.
+type TokenManager struct {
+	method     jwt.SigningMethod
+	keyID      string
+	signingKey interface{}
+	verifyKeys map[string]interface{}
+	issuer     string
+	audience   string
+	ttl        time.Duration
+	now        func() time.Time
+	newID      func() string
+}
+
+// TokenOption customises a TokenManager; tests use it to control time and
+// token IDs.
+type TokenOption func(*TokenManager)
+
+func WithClock(now func() time.Time) TokenOption {
+	return func(m *TokenManager) {
+		m.now = now
+	}
+}
+
+func WithIDGenerator(newID func() string) TokenOption {
+	return func(m *TokenManager) {
+		m.newID = newID
+	}
+}
+
+func NewTokenManager(cfg config.JWT, opts ...TokenOption) (*TokenManager, error) {
+	m := &TokenManager{
+		keyID:      cfg.SigningKeyID,
+		verifyKeys: make(map[string]interface{}),
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		ttl:        time.Duration(cfg.ExpirationTime) * time.Second,
+		now:        time.Now,
+		newID:      func() string { return uuid.New().String() },
+	}
+	if m.ttl <= 0 {
+		m.ttl = defaultTokenTTL
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	switch cfg.Algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		if cfg.SecretKey == "" {
+			return nil, errors.New("jwt: secret_key is required for HS256")
+		}
+		m.method = jwt.SigningMethodHS256
+		m.signingKey = []byte(cfg.SecretKey)
+		m.verifyKeys[m.keyID] = m.signingKey
+		return m, nil
+	case jwt.SigningMethodRS256.Alg():
+		m.method = jwt.SigningMethodRS256
+	case jwt.SigningMethodEdDSA.Alg():
+		m.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	for _, key := range cfg.Keys {
+		if key.ID == "" {
+			return nil, errors.New("jwt: every key must have an id")
+		}
+		private, public, err := m.loadKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %q: %w", key.ID, err)
+		}
+		m.verifyKeys[key.ID] = public
+		if key.ID == m.keyID {
+			if private == nil {
+				return nil, fmt.Errorf("jwt: signing key %q has no private_key_file", key.ID)
+			}
+			m.signingKey = private
+		}
+	}
+	if m.signingKey == nil {
+		return nil, fmt.Errorf("jwt: signing_key_id %q does not match any configured key", m.keyID)
+	}
+
+	return m, nil
+}
+
+func (m *TokenManager) loadKey(key config.JWTKey) (private, public interface{}, err error) {
+	if key.PrivateKeyFile != "" {
+		pem, err := os.ReadFile(key.PrivateKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch m.method {
+		case jwt.SigningMethodRS256:
+			rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, nil, err
+			}
+			return rsaKey, rsaKey.Public(), nil
+		default:
+			edKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, nil, err
+			}
+			return edKey, edKey.(ed25519.PrivateKey).Public(), nil
+		}
+	}
+
+	if key.PublicKeyFile == "" {
+		return nil, nil, errors.New("either private_key_file or public_key_file is required")
+	}
+	pem, err := os.ReadFile(key.PublicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch m.method {
+	case jwt.SigningMethodRS256:
+		public, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+	default:
+		public, err = jwt.ParseEdPublicKeyFromPEM(pem)
+	}
+	return nil, public, err
+}
+
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+func (m *TokenManager) GenerateToken(username, role string) (token string, tokenID string, err error) {
+	now := m.now()
+	tokenID = m.newID()
+
+	claims := Claims{
+		UserName: username,
+		Role:     role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    m.issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if m.audience != "" {
+		claims.Audience = jwt.ClaimStrings{m.audience}
+	}
+
+	t := jwt.NewWithClaims(m.method, claims)
+	if m.keyID != "" {
+		t.Header["kid"] = m.keyID
+	}
+	token, err = t.SignedString(m.signingKey)
+	if err != nil {
+		return "", "", err
+	}
+	return token, tokenID, nil
+}
+
+func (m *TokenManager) ValidateJWT(tokenString string, revocations RevocationList) (*Claims, error) {
+	// Time-based claims are checked below against the manager's clock.
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{m.method.Alg()}), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		key, ok := m.verifyKeys[keyID]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		// The error ends up in the logs, so it must not carry the token.
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	now := m.now()
+	switch {
+	case claims.ExpiresAt == nil:
+		// Every token we issue expires; one without exp would live forever.
+		return nil, ErrInvalidClaims
+	case !claims.VerifyExpiresAt(now, true):
+		return nil, jwt.ErrTokenExpired
+	case !claims.VerifyNotBefore(now, false):
+		return nil, jwt.ErrTokenNotValidYet
+	case !claims.VerifyIssuedAt(now, false):
+		return nil, jwt.ErrTokenUsedBeforeIssued
+	}
+	if !claims.VerifyIssuer(m.issuer, m.issuer != "") || !claims.VerifyAudience(m.audience, m.audience != "") {
+		return nil, ErrInvalidClaims
+	}
+
+	if revocations != nil {
+		revoked, err := revocations.IsTokenRevoked(claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// JWKS returns the public verification keys. HMAC secrets are never published,
+// so the set is empty for HS256.
+func (m *TokenManager) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for keyID, key := range m.verifyKeys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     keyID,
+				Use:       "sig",
+				Algorithm: m.method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     keyID,
+				Use:       "sig",
+				Algorithm: m.method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(k),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return set
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"TestAvito/internal/config"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type revokedTokens map[string]bool
+
+func (r revokedTokens) IsTokenRevoked(tokenID string) (bool, error) {
+	return r[tokenID], nil
+}
+
+func newTestManager(t *testing.T, cfg config.JWT) *TokenManager {
+	m, err := NewTokenManager(cfg)
+	require.NoError(t, err)
+	return m
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), blockType+".pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func writeRSAKey(t *testing.T) (privateFile, publicFile string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), writePEM(t, "PUBLIC KEY", public)
+}
+
+func writeEd25519Key(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, "PRIVATE KEY", der)
+}
+
+func TestGenerateToken(t *testing.T) {
+	m := newTestManager(t, config.JWT{SecretKey: "password"})
+	token, tokenID, err := m.GenerateToken("postgres", "employee")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, tokenID)
+
+	claims, err := m.ValidateJWT(token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", claims.UserName)
+	assert.Equal(t, "employee", claims.Role)
+	assert.Equal(t, tokenID, claims.ID)
+}
+
+func TestValidateJWT_ValidToken(t *testing.T) {
+	m := newTestManager(t, config.JWT{SecretKey: "password", ExpirationTime: 7200})
+	token, _, _ := m.GenerateToken("postgres", "employee")
+	claims, err := m.ValidateJWT(token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", claims.UserName)
+	assert.WithinDuration(t, time.Now(), claims.IssuedAt.Time, 10*time.Second)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), claims.ExpiresAt.Time, 10*time.Second)
+}
+
+func TestValidateJWT_InvalidSecret(t *testing.T) {
+	token, _, _ := newTestManager(t, config.JWT{SecretKey: "password"}).GenerateToken("postgres", "employee")
+	_, err := newTestManager(t, config.JWT{SecretKey: "wrongSecret"}).ValidateJWT(token, nil)
+	assert.Error(t, err)
+}
+
+func TestValidateJWT_RevokedToken(t *testing.T) {
+	m := newTestManager(t, config.JWT{SecretKey: "password"})
+	token, tokenID, _ := m.GenerateToken("postgres", "employee")
+
+	_, err := m.ValidateJWT(token, revokedTokens{})
+	assert.NoError(t, err)
+
+	_, err = m.ValidateJWT(token, revokedTokens{tokenID: true})
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
+
+func TestValidateJWT_UsesClock(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, err := NewTokenManager(config.JWT{SecretKey: "password", ExpirationTime: 60},
+		WithClock(func() time.Time { return now }),
+		WithIDGenerator(func() string { return "fixed-id" }))
+	require.NoError(t, err)
+
+	token, tokenID, err := m.GenerateToken("alice", "employee")
+	require.NoError(t, err)
+	assert.Equal(t, "fixed-id", tokenID)
+
+	claims, err := m.ValidateJWT(token, nil)
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Minute).Equal(claims.ExpiresAt.Time))
+
+	now = now.Add(2 * time.Minute)
+	_, err = m.ValidateJWT(token, nil)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestValidateJWT_RequiresExpiration(t *testing.T) {
+	m := newTestManager(t, config.JWT{SecretKey: "password"})
+
+	claims := Claims{UserName: "postgres", Role: "admin"}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("password"))
+	require.NoError(t, err)
+	_, err = m.ValidateJWT(token, nil)
+	assert.ErrorIs(t, err, ErrInvalidClaims)
+}
+
+func TestValidateJWT_IssuerAndAudience(t *testing.T) {
+	issuer := newTestManager(t, config.JWT{SecretKey: "password", Issuer: "merch-store", Audience: "merch-store"})
+	token, _, _ := issuer.GenerateToken("postgres", "employee")
+
+	claims, err := issuer.ValidateJWT(token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "merch-store", claims.Issuer)
+
+	other := newTestManager(t, config.JWT{SecretKey: "password", Issuer: "merch-store", Audience: "billing"})
+	_, err = other.ValidateJWT(token, nil)
+	assert.ErrorIs(t, err, ErrInvalidClaims)
+}
+
+func TestValidateJWT_RejectsOtherAlgorithm(t *testing.T) {
+	m := newTestManager(t, config.JWT{SecretKey: "password"})
+
+	claims := Claims{UserName: "postgres", RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte("password"))
+	require.NoError(t, err)
+	_, err = m.ValidateJWT(token, nil)
+	assert.Error(t, err)
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = m.ValidateJWT(token, nil)
+	assert.Error(t, err)
+}
+
+func TestTokenManager_RS256KeyRotation(t *testing.T) {
+	oldPrivate, oldPublic := writeRSAKey(t)
+	newPrivate, _ := writeRSAKey(t)
+
+	old := newTestManager(t, config.JWT{
+		Algorithm:    "RS256",
+		SigningKeyID: "old",
+		Keys:         []config.JWTKey{{ID: "old", PrivateKeyFile: oldPrivate}},
+	})
+	oldToken, _, err := old.GenerateToken("postgres", "employee")
+	require.NoError(t, err)
+
+	rotated := newTestManager(t, config.JWT{
+		Algorithm:    "RS256",
+		SigningKeyID: "new",
+		Keys: []config.JWTKey{
+			{ID: "new", PrivateKeyFile: newPrivate},
+			{ID: "old", PublicKeyFile: oldPublic},
+		},
+	})
+	newToken, _, err := rotated.GenerateToken("postgres", "employee")
+	require.NoError(t, err)
+
+	_, err = rotated.ValidateJWT(oldToken, nil)
+	assert.NoError(t, err)
+	_, err = rotated.ValidateJWT(newToken, nil)
+	assert.NoError(t, err)
+	_, err = old.ValidateJWT(newToken, nil)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	jwks := rotated.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "new", jwks.Keys[0].KeyID)
+	assert.Equal(t, "RSA", jwks.Keys[0].KeyType)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+	assert.Equal(t, "old", jwks.Keys[1].KeyID)
+}
+
+func TestTokenManager_EdDSA(t *testing.T) {
+	m := newTestManager(t, config.JWT{
+		Algorithm:    "EdDSA",
+		SigningKeyID: "ed",
+		Keys:         []config.JWTKey{{ID: "ed", PrivateKeyFile: writeEd25519Key(t)}},
+	})
+	token, _, err := m.GenerateToken("postgres", "admin")
+	require.NoError(t, err)
+
+	claims, err := m.ValidateJWT(token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", claims.Role)
+
+	jwks := m.JWKS()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+	assert.Equal(t, "Ed25519", jwks.Keys[0].Curve)
+}
+
+func TestTokenManager_HS256PublishesNoKeys(t *testing.T) {
+	m := newTestManager(t, config.JWT{SecretKey: "password"})
+	assert.Empty(t, m.JWKS().Keys)
+}
+
+func TestNewTokenManager_InvalidConfig(t *testing.T) {
+	_, err := NewTokenManager(config.JWT{})
+	assert.Error(t, err)
+
+	_, err = NewTokenManager(config.JWT{Algorithm: "none", SecretKey: "password"})
+	assert.Error(t, err)
+
+	_, err = NewTokenManager(config.JWT{Algorithm: "RS256", SigningKeyID: "missing"})
+	assert.Error(t, err)
+}
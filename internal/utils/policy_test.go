@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	assert.NoError(t, ValidateUsername("ivan.petrov"))
+	assert.NoError(t, ValidateUsername("user_42"))
+	assert.ErrorIs(t, ValidateUsername("ab"), ErrInvalidUsername)
+	assert.ErrorIs(t, ValidateUsername(strings.Repeat("a", 33)), ErrInvalidUsername)
+	assert.ErrorIs(t, ValidateUsername("ivan petrov"), ErrInvalidUsername)
+	assert.ErrorIs(t, ValidateUsername("иван"), ErrInvalidUsername)
+}
+
+func TestValidatePassword(t *testing.T) {
+	assert.NoError(t, ValidatePassword("password1"))
+	assert.ErrorIs(t, ValidatePassword("pass1"), ErrWeakPassword)
+	assert.ErrorIs(t, ValidatePassword("password"), ErrWeakPassword)
+	assert.ErrorIs(t, ValidatePassword("12345678"), ErrWeakPassword)
+	assert.ErrorIs(t, ValidatePassword(strings.Repeat("a1", 40)), ErrWeakPassword)
+}
@@ -0,0 +1,18 @@
+package web
+
+import "context"
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the ID of the HTTP request that ctx belongs to,
+// or an empty string outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
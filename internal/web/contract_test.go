@@ -0,0 +1,274 @@
+package web
+
+import (
+	"TestAvito/internal/config"
+	"TestAvito/internal/metrics"
+	"TestAvito/internal/models"
+	"TestAvito/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const contractBaseURL = "http://localhost:8080"
+
+// contractClient sends requests through the real echo router and checks every
+// response against openapi.json.
+type contractClient struct {
+	t       *testing.T
+	server  *Server
+	storage *storage.Storage
+	router  routers.Router
+}
+
+func newContractClient(t *testing.T, opts ...Option) *contractClient {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+
+	st := storage.NewMemory()
+	jwtCfg := config.JWT{
+		SecretKey:             "contract-test-secret-contract-test-secret",
+		ExpirationTime:        900,
+		RefreshExpirationTime: 3600,
+		Issuer:                "merch-store",
+		Audience:              "merch-store",
+	}
+	server, err := New(config.Server{}, jwtCfg, config.Auth{}, slog.New(slog.NewTextHandler(io.Discard, nil)), st, opts...)
+	require.NoError(t, err)
+
+	return &contractClient{t: t, server: server, storage: st, router: router}
+}
+
+func (c *contractClient) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		require.NoError(c.t, err)
+	}
+
+	req := httptest.NewRequest(method, contractBaseURL+path, bytes.NewReader(payload))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	c.server.app.ServeHTTP(rec, req)
+
+	route, pathParams, err := c.router.FindRoute(req)
+	require.NoError(c.t, err, "%s %s is not described in openapi.json", method, path)
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status:  rec.Code,
+		Header:  rec.Header(),
+		Body:    io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	assert.NoError(c.t, err, "%s %s -> %d %s", method, path, rec.Code, rec.Body.String())
+
+	return rec
+}
+
+func (c *contractClient) login(username, password string) (string, string) {
+	rec := c.do(http.MethodPost, "/api/auth", "", models.AuthorizeUserRequest{Username: username, Password: password})
+	require.Equal(c.t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var tokens map[string]string
+	require.NoError(c.t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+	return tokens["token"], tokens["refresh_token"]
+}
+
+func TestContractAuth(t *testing.T) {
+	c := newContractClient(t)
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/openapi.json", "", nil).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/.well-known/jwks.json", "", nil).Code)
+
+	alice := models.RegisterUserRequest{Username: "alice", Password: "secret123"}
+	assert.Equal(t, http.StatusCreated, c.do(http.MethodPost, "/api/register", "", alice).Code)
+	assert.Equal(t, http.StatusConflict, c.do(http.MethodPost, "/api/register", "", alice).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity,
+		c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "a", Password: "short"}).Code)
+	assert.Equal(t, http.StatusBadRequest, c.do(http.MethodPost, "/api/register", "", "not an object").Code)
+
+	assert.Equal(t, http.StatusUnauthorized,
+		c.do(http.MethodPost, "/api/auth", "", models.AuthorizeUserRequest{Username: "alice", Password: "wrong-password1"}).Code)
+	token, refreshToken := c.login("alice", "secret123")
+
+	refresh := models.RefreshTokenRequest{RefreshToken: refreshToken}
+	assert.Equal(t, http.StatusOK, c.do(http.MethodPost, "/api/auth/refresh", "", refresh).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodPost, "/api/auth/refresh", "", refresh).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodGet, "/api/info", token, nil).Code)
+
+	token, _ = c.login("alice", "secret123")
+	assert.Equal(t, http.StatusNoContent, c.do(http.MethodPost, "/api/auth/logout", token, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodGet, "/api/info", token, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodGet, "/api/info", "", nil).Code)
+}
+
+func TestContractShop(t *testing.T) {
+	c := newContractClient(t)
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "alice", Password: "secret123"})
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "bob", Password: "secret123"})
+	alice, _ := c.login("alice", "secret123")
+	bob, _ := c.login("bob", "secret123")
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/products", "", nil).Code)
+
+	assert.Equal(t, http.StatusOK,
+		c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "bob", Amount: 100}).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity,
+		c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "alice", Amount: 1}).Code)
+	assert.Equal(t, http.StatusNotFound,
+		c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "nobody", Amount: 1}).Code)
+	assert.Equal(t, http.StatusConflict,
+		c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "bob", Amount: 5000}).Code)
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/buy/cup?quantity=2", bob, nil).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/buy/pen", bob, nil).Code)
+	assert.Equal(t, http.StatusNotFound, c.do(http.MethodGet, "/api/buy/sofa", bob, nil).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, c.do(http.MethodGet, "/api/buy/cup?quantity=0", bob, nil).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, c.do(http.MethodGet, "/api/buy/pen?quantity=1844674407370955061", bob, nil).Code)
+
+	rec := c.do(http.MethodGet, "/api/info", bob, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var info models.InfoResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, 1050, info.Coins)
+	assert.Equal(t, []models.InventoryItem{{Type: "cup", Quantity: 2}, {Type: "pen", Quantity: 1}}, info.Inventory)
+	assert.Equal(t, []models.TransactionsToUser{{FromUser: "alice", Amount: 100}}, info.CoinHistory.Received)
+	assert.Empty(t, info.CoinHistory.Sent)
+}
+
+func TestContractAdmin(t *testing.T) {
+	c := newContractClient(t)
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "admin", Password: "secret123"})
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "bob", Password: "secret123"})
+	user, err := c.storage.GetUserByUsername("admin")
+	require.NoError(t, err)
+	_, err = c.storage.UpdateUser(&models.User{ID: user.ID, Role: models.RoleAdmin})
+	require.NoError(t, err)
+	admin, _ := c.login("admin", "secret123")
+	bob, _ := c.login("bob", "secret123")
+
+	price := 5
+	product := models.UpsertProductRequest{Name: "sticker", Price: &price}
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodPost, "/api/admin/products", bob, product).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodPost, "/api/admin/products", admin, product).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodPut, "/api/admin/products/sticker", admin, map[string]int{"price": 7}).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, c.do(http.MethodPut, "/api/admin/products/sticker", admin, map[string]int{}).Code)
+	assert.Equal(t, http.StatusNoContent, c.do(http.MethodDelete, "/api/admin/products/sticker", admin, nil).Code)
+	assert.Equal(t, http.StatusNotFound, c.do(http.MethodDelete, "/api/admin/products/sofa", admin, nil).Code)
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/reports/reconciliation", admin, nil).Code)
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodGet, "/api/reports/reconciliation", bob, nil).Code)
+
+	assert.Equal(t, http.StatusNoContent, c.do(http.MethodPost, "/api/admin/users/bob/revoke-sessions", admin, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodGet, "/api/info", bob, nil).Code)
+}
+
+func TestContractLogLevel(t *testing.T) {
+	level := new(slog.LevelVar)
+	c := newContractClient(t, WithLogLevel(level))
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "admin", Password: "secret123"})
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "bob", Password: "secret123"})
+	user, err := c.storage.GetUserByUsername("admin")
+	require.NoError(t, err)
+	_, err = c.storage.UpdateUser(&models.User{ID: user.ID, Role: models.RoleAdmin})
+	require.NoError(t, err)
+	admin, _ := c.login("admin", "secret123")
+	bob, _ := c.login("bob", "secret123")
+
+	rec := c.do(http.MethodGet, "/api/admin/log-level", admin, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"info"}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodPut, "/api/admin/log-level", bob, models.LogLevelRequest{Level: "debug"}).Code)
+	assert.Equal(t, slog.LevelInfo, level.Level())
+
+	rec = c.do(http.MethodPut, "/api/admin/log-level", admin, models.LogLevelRequest{Level: "verbose"})
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "must be one of: debug, info, warn, error")
+
+	rec = c.do(http.MethodPut, "/api/admin/log-level", admin, models.LogLevelRequest{Level: "debug"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+	assert.Equal(t, slog.LevelDebug, level.Level())
+}
+
+func TestContractHealth(t *testing.T) {
+	c := newContractClient(t, WithHealthCheck("database", func(context.Context) error { return nil }))
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/healthz", "", nil).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/readyz", "", nil).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/version", "", nil).Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/metrics", "", nil).Code)
+
+	c = newContractClient(t,
+		WithHealthCheck("database", func(context.Context) error { return nil }),
+		WithHealthCheck("migrations", func(context.Context) error { return errors.New("schema version 5 is behind 6") }))
+
+	rec := c.do(http.MethodGet, "/readyz", "", nil)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var readiness ReadinessResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &readiness))
+	assert.Equal(t, "degraded", readiness.Status)
+	assert.Equal(t, []CheckResult{
+		{Name: "database", Status: "up"},
+		{Name: "migrations", Status: "down"},
+	}, readiness.Checks)
+	assert.NotContains(t, rec.Body.String(), "schema version")
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	c := newContractClient(t, WithMetrics(m))
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "alice", Password: "secret123"})
+	c.do(http.MethodPost, "/api/register", "", models.RegisterUserRequest{Username: "bob", Password: "secret123"})
+	alice, _ := c.login("alice", "secret123")
+
+	c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "bob", Amount: 100})
+	c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "bob", Amount: 5000})
+	c.do(http.MethodPost, "/api/sendCoin", alice, models.SendCoinRequest{RecipientUsername: "alice", Amount: 1})
+	c.do(http.MethodGet, "/api/buy/cup?quantity=3", alice, nil)
+	c.do(http.MethodGet, "/api/buy/sofa", alice, nil)
+
+	assert.Equal(t, 100.0, testutil.ToFloat64(m.CoinsTransferred))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.FailedTransfers.WithLabelValues(CodeInsufficientFunds)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.FailedTransfers.WithLabelValues(CodeSelfTransfer)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Purchases.WithLabelValues("cup")))
+	assert.Equal(t, 60.0, testutil.ToFloat64(m.CoinsSpent.WithLabelValues("cup")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.FailedPurchases.WithLabelValues(CodeProductNotFound)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.HTTPRequests.WithLabelValues(http.MethodPost, "/api/register", "201")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.HTTPRequests.WithLabelValues(http.MethodGet, "/api/buy/:item", "200")))
+
+	rec := c.do(http.MethodGet, "/metrics", "", nil)
+	assert.Contains(t, rec.Body.String(), `merch_coins_spent_total{product="cup"} 60`)
+}
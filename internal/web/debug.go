@@ -0,0 +1,21 @@
+package web
+
+import (
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerDebugHandlers serves net/http/pprof to admins; it is enabled by
+// server.debug_endpoints, which is only on by default in the dev profile.
+// Profiles expose memory contents and the command line, so the routes are
+// never public.
+func (s *Server) registerDebugHandlers(middleware ...echo.MiddlewareFunc) {
+	app := s.app
+	app.GET("/debug/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)), middleware...)
+	app.GET("/debug/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)), middleware...)
+	app.GET("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)), middleware...)
+	app.POST("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)), middleware...)
+	app.GET("/debug/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)), middleware...)
+	app.GET("/debug/pprof/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)), middleware...)
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"TestAvito/internal/storage"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo"
+	"golang.org/x/exp/slog"
+	"net/http"
+)
+
+const (
+	CodeBadRequest          = "bad_request"
+	CodeValidationFailed    = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeUserNotFound        = "user_not_found"
+	CodeRecipientNotFound   = "recipient_not_found"
+	CodeProductNotFound     = "product_not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeConflict            = "conflict"
+	CodeUserExists          = "user_exists"
+	CodeProductUnavailable  = "product_unavailable"
+	CodeInsufficientFunds   = "insufficient_funds"
+	CodeSelfTransfer        = "self_transfer"
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeInternal            = "internal_error"
+)
+
+// APIError is the single error shape returned by every endpoint. Handlers either
+// return one directly or return a domain error that HTTPErrorHandler maps.
+type APIError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func badRequest(message string) *APIError {
+	return NewAPIError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func unauthorized(message string) *APIError {
+	return NewAPIError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func validationFailed(details map[string]string) *APIError {
+	return &APIError{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    CodeValidationFailed,
+		Message: "request validation failed",
+		Details: details,
+	}
+}
+
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{storage.ErrUserNotFound, http.StatusNotFound, CodeUserNotFound},
+	{storage.ErrRecipientNotFound, http.StatusNotFound, CodeRecipientNotFound},
+	{storage.ErrProductNotFound, http.StatusNotFound, CodeProductNotFound},
+	{storage.ErrUserExists, http.StatusConflict, CodeUserExists},
+	{storage.ErrProductArchived, http.StatusConflict, CodeProductUnavailable},
+	{storage.ErrInsufficientFunds, http.StatusConflict, CodeInsufficientFunds},
+	{storage.ErrSelfTransfer, http.StatusUnprocessableEntity, CodeSelfTransfer},
+	{storage.ErrInvalidAmount, http.StatusUnprocessableEntity, CodeValidationFailed},
+	{storage.ErrInvalidQuantity, http.StatusUnprocessableEntity, CodeValidationFailed},
+	{storage.ErrInvalidRefreshToken, http.StatusUnauthorized, CodeInvalidRefreshToken},
+}
+
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	for _, d := range domainErrors {
+		if errors.Is(err, d.err) {
+			return NewAPIError(d.status, d.code, d.err.Error())
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		code := CodeInternal
+		switch httpErr.Code {
+		case http.StatusBadRequest:
+			code = CodeBadRequest
+		case http.StatusUnauthorized:
+			code = CodeUnauthorized
+		case http.StatusForbidden:
+			code = CodeForbidden
+		case http.StatusNotFound:
+			code = CodeNotFound
+		case http.StatusMethodNotAllowed:
+			code = CodeMethodNotAllowed
+		case http.StatusRequestEntityTooLarge:
+			code = CodePayloadTooLarge
+		}
+		return NewAPIError(httpErr.Code, code, fmt.Sprint(httpErr.Message))
+	}
+
+	return NewAPIError(http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// HTTPErrorHandler renders every error returned by handlers and middleware as an
+// ErrorResponse. Unexpected errors are logged and hidden from the client.
+func (s *Server) HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		s.logger.Error("Internal error",
+			slog.String("RequestID", RequestIDFromContext(c.Request().Context())),
+			slog.String("Error", err.Error()))
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status)
+	} else {
+		err = c.JSON(apiErr.Status, ErrorResponse{Error: apiErr})
+	}
+	if err != nil {
+		s.logger.Error("Failed to write error response", slog.String("Error", err.Error()))
+	}
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"TestAvito/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToAPIError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{storage.ErrRecipientNotFound, http.StatusNotFound, CodeRecipientNotFound},
+		{fmt.Errorf("purchase: %w", storage.ErrProductNotFound), http.StatusNotFound, CodeProductNotFound},
+		{storage.ErrUserExists, http.StatusConflict, CodeUserExists},
+		{storage.ErrInsufficientFunds, http.StatusConflict, CodeInsufficientFunds},
+		{storage.ErrInvalidAmount, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{echo.ErrNotFound, http.StatusNotFound, CodeNotFound},
+		{echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{badRequest("invalid request body"), http.StatusBadRequest, CodeBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		apiErr := toAPIError(tt.err)
+		assert.Equal(t, tt.status, apiErr.Status, tt.err.Error())
+		assert.Equal(t, tt.code, apiErr.Code, tt.err.Error())
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	s := &Server{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	s.HTTPErrorHandler(validationFailed(map[string]string{"amount": "must be at least 1"}), c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, CodeValidationFailed, body["error"]["code"])
+	assert.Equal(t, map[string]interface{}{"amount": "must be at least 1"}, body["error"]["details"])
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	s.HTTPErrorHandler(errors.New("pq: password authentication failed"), c)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "password")
+}
@@ -2,187 +2,390 @@ package web
 
 import (
 	"TestAvito/internal/models"
+	"TestAvito/internal/storage"
 	"TestAvito/internal/utils"
+	"bytes"
 	"errors"
 	"github.com/labstack/echo"
-	"gorm.io/gorm"
+	"io"
 	"net/http"
+	"time"
 )
 
 func (s *Server) RegisterHandlers(m *Middleware) {
 	app := s.app
 
-	apiGroup := app.Group("/api")
-	apiGroup.POST("/auth", s.Authorize)
-	apiGroup.POST("/sendCoin", s.SendCoin, m.AccessLog())
-	apiGroup.GET("/buy/:item", s.BuyItem, m.AccessLog())
-	apiGroup.GET("/info", s.GetUserInfo, m.AccessLog())
+	app.GET("/healthz", s.Healthz)
+	app.GET("/readyz", s.Readyz)
+	app.GET("/version", s.Version)
+	app.GET("/metrics", echo.WrapHandler(s.metrics.Handler()))
+	app.GET("/.well-known/jwks.json", s.JWKS)
+
+	// Routes are registered without echo groups: Group.Use adds catch-all
+	// routes under the prefix that run the group middleware, which would turn
+	// 404/405 for public /api paths into 401.
+	app.GET("/api/openapi.json", s.OpenAPI)
+	app.POST("/api/register", s.Register)
+	app.POST("/api/auth", s.Authorize)
+	app.POST("/api/auth/refresh", s.RefreshToken)
+	app.GET("/api/products", s.ListProducts)
+
+	auth := m.Authenticate()
+	app.POST("/api/auth/logout", s.Logout, auth)
+	app.POST("/api/sendCoin", s.SendCoin, auth)
+	app.GET("/api/buy/:item", s.BuyItem, auth)
+	app.GET("/api/info", s.GetUserInfo, auth)
+
+	admin := m.RequireRole(models.RoleAdmin)
+	app.POST("/api/admin/products", s.CreateProduct, auth, admin)
+	app.PUT("/api/admin/products/:name", s.UpdateProduct, auth, admin)
+	app.DELETE("/api/admin/products/:name", s.ArchiveProduct, auth, admin)
+	app.POST("/api/admin/users/:username/revoke-sessions", s.RevokeUserSessions, auth, admin)
+	// Without the logger's level there is nothing to change.
+	if s.logLevel != nil {
+		app.GET("/api/admin/log-level", s.GetLogLevel, auth, admin)
+		app.PUT("/api/admin/log-level", s.SetLogLevel, auth, admin)
+	}
+	if s.config.DebugEndpoints {
+		s.registerDebugHandlers(auth, admin)
+	}
+
+	reports := m.RequireRole(models.RoleAdmin, models.RoleAuditor)
+	app.GET("/api/reports/reconciliation", s.Reconciliation, auth, reports)
+}
+
+func (s *Server) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.tokens.JWKS())
+}
+
+func (s *Server) Register(c echo.Context) error {
+	var req models.RegisterUserRequest
+
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	user, err := s.createUser(req.Username, req.Password)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, tokens)
 }
 
 func (s *Server) Authorize(c echo.Context) error {
 	var req models.AuthorizeUserRequest
 
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
 	user, err := s.Storage.GetUserByUsername(req.Username)
-
-	if err != nil {
-		hashedPassword, err := utils.HashPassword(req.Password)
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound) && s.Auth.AutoRegister:
+		user, err = s.createUser(req.Username, req.Password)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Password hashing error"})
-		}
-		createdUser, createErr := s.Storage.CreateUser(req.Username, hashedPassword)
-		if createErr != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
+			return err
 		}
-		user = createdUser
-	} else {
+	case errors.Is(err, storage.ErrUserNotFound):
+		return NewAPIError(http.StatusUnauthorized, CodeInvalidCredentials, "invalid username or password")
+	case err != nil:
+		return err
+	default:
 		if !utils.CheckPassword(req.Password, user.Password) {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
+			return NewAPIError(http.StatusUnauthorized, CodeInvalidCredentials, "invalid username or password")
 		}
 	}
 
-	token, err := utils.GenerateToken(user.Username, s.JWT.SecretKey)
+	tokens, err := s.issueTokens(user)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return err
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"token": token})
+	return c.JSON(http.StatusOK, tokens)
 }
 
-func (s *Server) SendCoin(c echo.Context) error {
-	username, ok := c.Get("user_name").(string)
-	if !ok {
-		return c.JSON(http.StatusBadRequest, "missing token")
+func (s *Server) RefreshToken(c echo.Context) error {
+	var req models.RefreshTokenRequest
+
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	var req models.SendCoinRequest
+	refreshToken, err := s.Storage.UseRefreshToken(c.Request().Context(), utils.HashRefreshToken(req.RefreshToken), s.now())
+	if err != nil {
+		return err
+	}
 
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, "Bad request")
+	user, err := s.Storage.GetUserByID(refreshToken.UserID)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return NewAPIError(http.StatusUnauthorized, CodeInvalidRefreshToken, "refresh token owner no longer exists")
+	} else if err != nil {
+		return err
 	}
 
-	user, err := s.Storage.GetUserByUsername(username)
+	tokens, err := s.issueTokens(user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (s *Server) Logout(c echo.Context) error {
+	var req models.LogoutRequest
+
+	// The refresh token is optional, and echo refuses to bind an empty body.
+	// ContentLength is -1 for chunked requests, so look at the body itself.
+	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+		c.Request().ContentLength = int64(len(body))
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+	}
+
+	tokenID, _ := c.Get("token_id").(string)
+	expiresAt, _ := c.Get("token_expires_at").(time.Time)
+	if err := s.Storage.RevokeAccessToken(tokenID, expiresAt); err != nil {
+		return err
+	}
+
+	if req.RefreshToken != "" {
+		err := s.Storage.RevokeRefreshToken(c.Request().Context(), utils.HashRefreshToken(req.RefreshToken), s.now())
+		if err != nil {
+			return err
+		}
 	}
 
-	recipient, err := s.Storage.GetUserByUsername(req.RecipientUsername)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) RevokeUserSessions(c echo.Context) error {
+	user, err := s.Storage.GetUserByUsername(c.Param("username"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
 	}
 
-	if user.Coins < req.Amount {
-		return c.JSON(http.StatusBadRequest, "Not enough money")
+	err = s.Storage.RevokeUserSessions(c.Request().Context(), user.ID, s.now())
+	if err != nil {
+		return err
 	}
 
-	user.Coins -= req.Amount
-	recipient.Coins += req.Amount
+	return c.NoContent(http.StatusNoContent)
+}
 
-	user, recipient, err = s.Storage.UpdateTwoUsers(user, recipient)
+// issueTokens creates a short-lived access token and a refresh token bound to
+// it, so that revoking the refresh token also revokes the access token.
+func (s *Server) issueTokens(user *models.User) (map[string]string, error) {
+	accessToken, tokenID, err := s.tokens.GenerateToken(user.Username, user.Role)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return nil, err
 	}
 
-	transaction, err := s.Storage.CreateTransaction(user.ID, recipient.ID, req.Amount)
+	refreshToken, hash, err := utils.GenerateRefreshToken()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return nil, err
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":        user,
-		"recipient":   recipient,
-		"transaction": transaction,
+	now := s.now()
+	err = s.Storage.CreateRefreshToken(&models.RefreshToken{
+		UserID:               user.ID,
+		TokenHash:            hash,
+		AccessTokenID:        tokenID,
+		AccessTokenExpiresAt: now.Add(s.tokens.TTL()),
+		ExpiresAt:            now.Add(time.Duration(s.JWT.RefreshExpirationTime) * time.Second),
+		CreatedAt:            now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+// createUser re-checks the password policy because auto-registration through
+// /api/auth only validates the credentials format.
+func (s *Server) createUser(username, password string) (*models.User, error) {
+	if err := utils.ValidatePassword(password); err != nil {
+		return nil, validationFailed(map[string]string{"password": err.Error()})
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Storage.CreateUser(username, hashedPassword)
+}
+
+// bindAndValidate decodes the request into req and runs the registered validator.
+func bindAndValidate(c echo.Context, req interface{}) error {
+	if err := c.Bind(req); err != nil {
+		return badRequest("invalid request body")
+	}
+	return c.Validate(req)
+}
+
+func (s *Server) SendCoin(c echo.Context) error {
+	username, ok := c.Get("user_name").(string)
+	if !ok {
+		return unauthorized("missing token")
+	}
+
+	var req models.SendCoinRequest
+
+	if err := bindAndValidate(c, &req); err != nil {
+		return s.transferFailed(err)
+	}
+	if req.RecipientUsername == username {
+		return s.transferFailed(&APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeSelfTransfer,
+			Message: "cannot send coins to yourself",
+			Details: map[string]string{"recipient_username": "must differ from the sender"},
+		})
+	}
+
+	transaction, err := s.Storage.Transfer(c.Request().Context(), username, req.RecipientUsername, req.Amount)
+	if err != nil {
+		return s.transferFailed(err)
+	}
+	s.metrics.ObserveTransfer(transaction.Amount)
+
+	user, err := s.Storage.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.SendCoinResponse{
+		ToUser: req.RecipientUsername,
+		Amount: transaction.Amount,
+		Coins:  user.Coins,
 	})
 }
 
 func (s *Server) BuyItem(c echo.Context) error {
 	username, ok := c.Get("user_name").(string)
 	if !ok {
-		return c.JSON(http.StatusBadRequest, "missing token")
+		return unauthorized("missing token")
 	}
 
-	var req models.BuyItemRequest
+	req := models.BuyItemRequest{Quantity: 1}
 
 	itemName := c.Param("item")
 	if itemName == "" {
-		return c.JSON(http.StatusBadRequest, "Item name is required")
+		return s.purchaseFailed(validationFailed(map[string]string{"item": "is required"}))
 	}
 
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, "Bad request")
+	if err := bindAndValidate(c, &req); err != nil {
+		return s.purchaseFailed(err)
 	}
 
 	user, err := s.Storage.GetUserByUsername(username)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
 	}
 
-	price, err := s.Storage.GetItemPrice(itemName)
+	purchase, inventory, err := s.Storage.Purchase(c.Request().Context(), user.ID, itemName, req.Quantity)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return s.purchaseFailed(err)
 	}
+	s.metrics.ObservePurchase(purchase.ItemType, purchase.Amount)
 
-	if user.Coins < price*req.Quantity {
-		return c.JSON(http.StatusBadRequest, "Not enough money")
+	user, err = s.Storage.GetUserByUsername(username)
+	if err != nil {
+		return err
 	}
 
-	user.Coins -= price * req.Quantity
+	return c.JSON(http.StatusOK, models.BuyItemResponse{
+		Item:     purchase.ItemType,
+		Quantity: purchase.Quantity,
+		Price:    purchase.Price,
+		Total:    purchase.Amount,
+		Coins:    user.Coins,
+		Owned:    inventory.Quantity,
+	})
+}
 
-	user, err = s.Storage.UpdateUser(user)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
-	}
+// transferFailed and purchaseFailed count rejected operations by error code
+// and pass the error through.
+func (s *Server) transferFailed(err error) error {
+	s.metrics.FailedTransfers.WithLabelValues(toAPIError(err).Code).Inc()
+	return err
+}
 
-	inventory, err := s.Storage.UpdateInventory(user.ID, itemName, req.Quantity)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		inventory, err = s.Storage.CreateInventory(user.ID, itemName, req.Quantity)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, "Internal server error")
-		}
-	} else if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+func (s *Server) purchaseFailed(err error) error {
+	s.metrics.FailedPurchases.WithLabelValues(toAPIError(err).Code).Inc()
+	return err
+}
+
+func (s *Server) Reconciliation(c echo.Context) error {
+	discrepancies, err := s.Storage.Reconcile(c.Request().Context())
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":       user,
-		"item_price": price,
-		"inventory":  inventory,
+		"balanced":      len(discrepancies) == 0,
+		"discrepancies": discrepancies,
 	})
 }
 
 func (s *Server) GetUserInfo(c echo.Context) error {
 	username, ok := c.Get("user_name").(string)
 	if !ok {
-		return c.JSON(http.StatusBadRequest, "Missing token")
+		return unauthorized("missing token")
 	}
 
 	user, err := s.Storage.GetUserByUsername(username)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
 	}
 
 	inventory, err := s.Storage.GetPurchasedItems(user.ID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
 	}
 
 	transactionsFromUser, err := s.Storage.GetGiftsGivenByUser(user.ID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
 	}
 
 	transactionsToUser, err := s.Storage.GetGiftsGivenToUser(user.ID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Internal server error")
+		return err
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":                   user,
-		"inventory":              inventory,
-		"transactions_from_user": transactionsFromUser,
-		"transactions_to_user":   transactionsToUser,
-	})
+	response := models.InfoResponse{
+		Coins:     user.Coins,
+		Inventory: make([]models.InventoryItem, 0, len(inventory)),
+		CoinHistory: models.CoinHistory{
+			Received: make([]models.TransactionsToUser, 0, len(transactionsToUser)),
+			Sent:     make([]models.TransactionsFromUser, 0, len(transactionsFromUser)),
+		},
+	}
+	for _, item := range inventory {
+		response.Inventory = append(response.Inventory, models.InventoryItem{
+			Type:     item.ItemType,
+			Quantity: item.Quantity,
+		})
+	}
+	response.CoinHistory.Received = append(response.CoinHistory.Received, transactionsToUser...)
+	response.CoinHistory.Sent = append(response.CoinHistory.Sent, transactionsFromUser...)
+
+	return c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"TestAvito/internal/version"
+	"context"
+	"github.com/labstack/echo"
+	"golang.org/x/exp/slog"
+	"net/http"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// WithHealthCheck adds a dependency check to /readyz. The service reports
+// itself degraded while any check fails.
+func WithHealthCheck(name string, check func(ctx context.Context) error) Option {
+	return func(s *Server) {
+		s.healthChecks = append(s.healthChecks, healthCheck{name: name, check: check})
+	}
+}
+
+// Healthz is the liveness probe: it only tells that the process serves HTTP.
+func (s *Server) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz is the readiness probe. It is public, so failure details only go to
+// the log.
+func (s *Server) Readyz(c echo.Context) error {
+	response := ReadinessResponse{
+		Status: "ready",
+		Checks: make([]CheckResult, 0, len(s.healthChecks)),
+	}
+
+	for _, hc := range s.healthChecks {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), healthCheckTimeout)
+		err := hc.check(ctx)
+		cancel()
+
+		result := CheckResult{Name: hc.name, Status: "up"}
+		if err != nil {
+			result.Status = "down"
+			s.logger.Warn("Health check failed",
+				slog.String("Check", hc.name),
+				slog.String("Error", err.Error()))
+			response.Status = "degraded"
+		}
+		response.Checks = append(response.Checks, result)
+	}
+
+	if response.Status != "ready" {
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (s *Server) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, version.Get())
+}
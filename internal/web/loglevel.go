@@ -0,0 +1,37 @@
+package web
+
+import (
+	"TestAvito/internal/logger"
+	"TestAvito/internal/models"
+	"github.com/labstack/echo"
+	"golang.org/x/exp/slog"
+	"net/http"
+)
+
+func (s *Server) GetLogLevel(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.LogLevelResponse{Level: logger.LevelName(s.logLevel.Level())})
+}
+
+func (s *Server) SetLogLevel(c echo.Context) error {
+	var req models.LogLevelRequest
+
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil {
+		return err
+	}
+
+	previous := s.logLevel.Level()
+	s.logLevel.Set(level)
+	username, _ := c.Get("user_name").(string)
+	s.logger.Warn("log level changed",
+		slog.String("from", logger.LevelName(previous)),
+		slog.String("to", req.Level),
+		slog.String("by", username),
+	)
+
+	return c.JSON(http.StatusOK, models.LogLevelResponse{Level: req.Level})
+}
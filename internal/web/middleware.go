@@ -1,91 +1,169 @@
 package web
 
 import (
-	"TestAvito/internal/config"
+	"TestAvito/internal/metrics"
 	"TestAvito/internal/utils"
 	"github.com/google/uuid"
 	"github.com/labstack/echo"
 	"golang.org/x/exp/slog"
-	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const headerRequestID = "X-Request-ID"
+
+// validRequestID limits client-supplied request IDs, which end up in logs and
+// response headers, to short tokens.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
 type Middleware struct {
-	logger *slog.Logger
-	JWT    config.JWT
+	logger      *slog.Logger
+	tokens      *utils.TokenManager
+	revocations utils.RevocationList
+	newID       func() string
+	metrics     *metrics.Metrics
 }
 
-func NewMiddleware(cfg config.JWT, logger *slog.Logger) *Middleware {
+func NewMiddleware(tokens *utils.TokenManager, logger *slog.Logger, revocations utils.RevocationList, metrics *metrics.Metrics) *Middleware {
 	return &Middleware{
-		logger: logger,
-		JWT:    cfg,
+		logger:      logger,
+		tokens:      tokens,
+		revocations: revocations,
+		newID:       func() string { return uuid.New().String() },
+		metrics:     metrics,
 	}
 }
 
 func (m *Middleware) Register(router *echo.Echo) {
-	router.Use(m.AccessLog())
+	router.Use(m.HTTPMetrics())
+	router.Use(m.RequestLog())
 }
 
-func (m *Middleware) AccessLog() echo.MiddlewareFunc {
+// HTTPMetrics counts requests and observes their latency by route pattern. It
+// wraps RequestLog, which turns handler errors into responses, so the final
+// status code is known here.
+func (m *Middleware) HTTPMetrics() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			startTime := time.Now()
+			err := next(c)
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			method := c.Request().Method
+			m.metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().Status)).Inc()
+			m.metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(startTime).Seconds())
+
+			return err
+		}
+	}
+}
 
-			requestID := uuid.New().String()
+// RequestLog assigns every request an ID, taken from the X-Request-ID header
+// when the client sends a valid one, and logs the outcome once the handler is done.
+func (m *Middleware) RequestLog() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			startTime := time.Now()
+			req := c.Request()
+
+			requestID := req.Header.Get(headerRequestID)
+			if !validRequestID.MatchString(requestID) {
+				requestID = m.newID()
+			}
 			c.Set("requestID", requestID)
+			c.SetRequest(req.WithContext(withRequestID(req.Context(), requestID)))
+			c.Response().Header().Set(headerRequestID, requestID)
+
+			handlerErr := next(c)
+			if handlerErr != nil {
+				c.Error(handlerErr)
+			}
 
-			m.logger.Info("Request started",
+			status := c.Response().Status
+			userName, _ := c.Get("user_name").(string)
+			attrs := []any{
 				slog.String("RequestID", requestID),
 				slog.String("IP", c.RealIP()),
-				slog.String("URL", c.Request().URL.Path),
-				slog.String("Method", c.Request().Method),
-			)
+				slog.String("Method", req.Method),
+				slog.String("URL", req.URL.Path),
+				slog.Int("Status", status),
+				slog.Duration("Latency", time.Since(startTime)),
+				slog.Int64("Bytes", c.Response().Size),
+				slog.String("User", userName),
+			}
+			if handlerErr != nil && status >= http.StatusInternalServerError {
+				m.logger.Error("Request failed", append(attrs, slog.String("Error", handlerErr.Error()))...)
+			} else if handlerErr != nil {
+				m.logger.Warn("Request rejected", append(attrs, slog.String("Error", handlerErr.Error()))...)
+			} else {
+				m.logger.Info("Request done", attrs...)
+			}
+
+			return nil
+		}
+	}
+}
+
+// Authenticate requires a valid bearer token and puts the user's name, role
+// and token ID into the context for the handlers behind it.
+func (m *Middleware) Authenticate() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestID, _ := c.Get("requestID").(string)
 
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
 				m.logger.Warn("Authorization header missing",
 					slog.String("RequestID", requestID))
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Отсутствует токен авторизации"})
+				return unauthorized("missing authorization token")
 			}
 
-			log.Println(authHeader)
-
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "некорректный формат токена"})
+				return unauthorized("authorization header must be 'Bearer <token>'")
 			}
 
-			log.Println(parts[1])
-
-			tokenString := parts[1]
-
-			log.Println("lololool", m.JWT.SecretKey)
-			claims, err := utils.ValidateJWT(tokenString, m.JWT.SecretKey)
-			log.Println("CLAIMS", claims, err)
+			claims, err := m.tokens.ValidateJWT(parts[1], m.revocations)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "токен недействителен"})
+				m.logger.Warn("Invalid token",
+					slog.String("RequestID", requestID),
+					slog.String("Error", err.Error()))
+				return unauthorized("invalid or expired token")
 			}
 
-			log.Println(claims)
 			c.Set("user_name", claims.UserName)
-			handlerErr := next(c)
+			c.Set("user_role", claims.Role)
+			c.Set("token_id", claims.ID)
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
 
-			responseTime := time.Since(startTime)
-			if handlerErr != nil {
-				m.logger.Error("Request Failed",
-					slog.String("RequestID", requestID),
-					slog.String("Time spent", strconv.FormatInt(int64(responseTime), 10)),
-					slog.String("Error", handlerErr.Error()))
-			} else {
-				m.logger.Info("Request done",
-					slog.String("RequestID", requestID),
-					slog.String("Time spent", strconv.FormatInt(int64(responseTime), 10)),
-				)
+			return next(c)
+		}
+	}
+}
+
+// RequireRole rejects requests whose token does not carry one of the given
+// roles. It must run after Authenticate, which puts the role into the context.
+func (m *Middleware) RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("user_role").(string)
+			requestID, _ := c.Get("requestID").(string)
+			for _, allowed := range roles {
+				if role == allowed {
+					return next(c)
+				}
 			}
-			return err
+
+			m.logger.Warn("Access denied",
+				slog.String("RequestID", requestID),
+				slog.String("Role", role))
+			return NewAPIError(http.StatusForbidden, CodeForbidden, "insufficient permissions")
 		}
 	}
 }
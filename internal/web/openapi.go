@@ -0,0 +1,17 @@
+package web
+
+import (
+	_ "embed"
+	"github.com/labstack/echo"
+	"net/http"
+)
+
+// openAPISpec describes every route registered in RegisterHandlers; the
+// contract tests validate real responses against it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func (s *Server) OpenAPI(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, openAPISpec)
+}
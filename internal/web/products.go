@@ -0,0 +1,72 @@
+package web
+
+import (
+	"TestAvito/internal/models"
+	"github.com/labstack/echo"
+	"net/http"
+)
+
+func (s *Server) ListProducts(c echo.Context) error {
+	products, err := s.Storage.ListProducts()
+	if err != nil {
+		return err
+	}
+
+	result := make([]models.ProductInfo, 0, len(products))
+	for _, product := range products {
+		result = append(result, models.ProductInfo{
+			Name:      product.Name,
+			Price:     product.Price,
+			Available: !product.Archived,
+		})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) CreateProduct(c echo.Context) error {
+	var req models.UpsertProductRequest
+
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	return s.upsertProduct(c, req)
+}
+
+func (s *Server) UpdateProduct(c echo.Context) error {
+	var req models.UpsertProductRequest
+
+	if err := c.Bind(&req); err != nil {
+		return badRequest("invalid request body")
+	}
+	req.Name = c.Param("name")
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	return s.upsertProduct(c, req)
+}
+
+func (s *Server) upsertProduct(c echo.Context, req models.UpsertProductRequest) error {
+	product, err := s.Storage.UpsertProduct(req.Name, *req.Price)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.ProductInfo{
+		Name:      product.Name,
+		Price:     product.Price,
+		Available: !product.Archived,
+	})
+}
+
+func (s *Server) ArchiveProduct(c echo.Context) error {
+	err := s.Storage.ArchiveProduct(c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"TestAvito/internal/utils"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+)
+
+// RequestValidator implements echo.Validator on top of go-playground/validator.
+// Validation failures are returned as a 422 APIError with one message per field.
+type RequestValidator struct {
+	validate *validator.Validate
+}
+
+func NewValidator() *RequestValidator {
+	v := validator.New()
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+	_ = v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return utils.ValidateUsername(fl.Field().String()) == nil
+	})
+	_ = v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return utils.ValidatePassword(fl.Field().String()) == nil
+	})
+
+	return &RequestValidator{validate: v}
+}
+
+func (v *RequestValidator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	details := make(map[string]string, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		details[fe.Field()] = fieldErrorMessage(fe)
+	}
+	return validationFailed(details)
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "username":
+		return utils.ErrInvalidUsername.Error()
+	case "password":
+		return utils.ErrWeakPassword.Error()
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"TestAvito/internal/models"
+	"TestAvito/internal/utils"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestValidatorFieldDetails(t *testing.T) {
+	v := NewValidator()
+
+	err := v.Validate(&models.SendCoinRequest{RecipientUsername: "a b", Amount: 100001})
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Status)
+	assert.Equal(t, CodeValidationFailed, apiErr.Code)
+	assert.Equal(t, map[string]string{
+		"recipient_username": utils.ErrInvalidUsername.Error(),
+		"amount":             "must be at most 100000",
+	}, apiErr.Details)
+}
+
+func TestValidatorRules(t *testing.T) {
+	v := NewValidator()
+
+	assert.NoError(t, v.Validate(&models.SendCoinRequest{RecipientUsername: "bob", Amount: 10}))
+	assert.NoError(t, v.Validate(&models.BuyItemRequest{Quantity: 1}))
+	assert.NoError(t, v.Validate(&models.RegisterUserRequest{Username: "alice", Password: "secret123"}))
+
+	assert.Error(t, v.Validate(&models.SendCoinRequest{RecipientUsername: "bob"}))
+	assert.Error(t, v.Validate(&models.BuyItemRequest{Quantity: -1}))
+	assert.Error(t, v.Validate(&models.AuthorizeUserRequest{Username: "al", Password: "x"}))
+	assert.Error(t, v.Validate(&models.RegisterUserRequest{Username: "alice", Password: "short"}))
+	price := -1
+	assert.Error(t, v.Validate(&models.UpsertProductRequest{Name: "pen", Price: &price}))
+	assert.Error(t, v.Validate(&models.UpsertProductRequest{Name: "pen"}), "a missing price must not mean free")
+}
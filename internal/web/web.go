@@ -2,47 +2,202 @@ package web
 
 import (
 	"TestAvito/internal/config"
+	"TestAvito/internal/metrics"
 	"TestAvito/internal/storage"
+	"TestAvito/internal/utils"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
 	"golang.org/x/exp/slog"
 	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 15 * time.Second
+	// tokenPruneInterval is how often Serve deletes expired refresh tokens
+	// and revoked access token IDs.
+	tokenPruneInterval = time.Hour
 )
 
 type Server struct {
 	app     *echo.Echo
 	URL     string
+	config  config.Server
 	logger  *slog.Logger
 	Storage *storage.Storage
 	JWT     config.JWT
+	Auth    config.Auth
+	tokens  *utils.TokenManager
+	now     func() time.Time
+	newID   func() string
+	metrics *metrics.Metrics
+	// logLevel is changed through /api/admin/log-level.
+	logLevel *slog.LevelVar
+
+	healthChecks []healthCheck
 }
 
-func New(srvCfg config.Server, Jwt config.JWT, logger *slog.Logger, storage *storage.Storage) (*Server, error) {
+// Option customises a Server; tests use it to make time and generated IDs
+// deterministic.
+type Option func(*Server)
+
+// WithClock replaces time.Now for token timestamps and expiry checks.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithIDGenerator replaces the UUID generator used for request and token IDs.
+func WithIDGenerator(newID func() string) Option {
+	return func(s *Server) {
+		s.newID = newID
+	}
+}
+
+// WithMetrics makes the server record into m instead of a private registry,
+// so that collectors registered elsewhere are served on /metrics too.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// WithLogLevel lets admins change level at runtime; it should be the level
+// the logger was built with. Without it /api/admin/log-level is not served.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(s *Server) {
+		s.logLevel = level
+	}
+}
+
+func New(srvCfg config.Server, Jwt config.JWT, auth config.Auth, logger *slog.Logger, storage *storage.Storage, opts ...Option) (*Server, error) {
 	e := echo.New()
 	server := Server{
 		app:     e,
 		URL:     srvCfg.Url,
+		config:  srvCfg,
 		logger:  logger,
 		Storage: storage,
 		JWT:     Jwt,
+		Auth:    auth,
+		now:     time.Now,
+		newID:   func() string { return uuid.New().String() },
 	}
+	for _, opt := range opts {
+		opt(&server)
+	}
+	if server.metrics == nil {
+		server.metrics = metrics.New()
+	}
+
+	tokens, err := utils.NewTokenManager(Jwt, utils.WithClock(server.now), utils.WithIDGenerator(server.newID))
+	if err != nil {
+		return nil, err
+	}
+	server.tokens = tokens
 	e.HideBanner = true
 	e.Logger.SetOutput(io.Discard)
+	e.HTTPErrorHandler = server.HTTPErrorHandler
+	e.Validator = NewValidator()
+
+	m := NewMiddleware(tokens, logger, storage, server.metrics)
+	m.newID = server.newID
+	m.Register(e)
 
 	e.Use(middleware.Recover())
+	if srvCfg.BodyLimit != "" {
+		e.Use(middleware.BodyLimit(srvCfg.BodyLimit))
+	}
 	e.Use(middleware.Secure())
 	e.Use(middleware.CORS())
 
-	m := NewMiddleware(Jwt, logger)
-
 	server.RegisterHandlers(m)
 
 	return &server, nil
 }
 
-func (s *Server) Serve() error {
-	s.logger.Info("HTTP server started", slog.String("url", s.URL))
+// pruneTokens deletes expired tokens every interval until ctx is cancelled.
+func (s *Server) pruneTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Storage.PruneExpiredTokens(ctx, s.now()); err != nil {
+				s.logger.Error("prune expired tokens", slog.String("Error", err.Error()))
+			}
+		}
+	}
+}
+
+// Handler exposes the configured router, e.g. for httptest servers.
+func (s *Server) Handler() http.Handler {
+	return s.app
+}
+
+// Serve listens until ctx is cancelled, then stops accepting connections and
+// waits up to the configured shutdown timeout for in-flight requests.
+func (s *Server) Serve(ctx context.Context) error {
+	if (s.config.TLSCertFile == "") != (s.config.TLSKeyFile == "") {
+		return errors.New("server: tls_cert_file and tls_key_file must be set together")
+	}
+
+	srv := &http.Server{
+		Addr:           s.URL,
+		Handler:        s.app,
+		ReadTimeout:    s.config.ReadTimeout,
+		WriteTimeout:   s.config.WriteTimeout,
+		IdleTimeout:    s.config.IdleTimeout,
+		MaxHeaderBytes: s.config.MaxHeaderBytes,
+	}
+
+	listener, err := net.Listen("tcp", s.URL)
+	if err != nil {
+		return fmt.Errorf("server error: %w", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if s.config.TLSCertFile != "" {
+			errCh <- srv.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			errCh <- srv.Serve(listener)
+		}
+	}()
+	s.logger.Info("HTTP server started", slog.String("url", listener.Addr().String()), slog.Bool("tls", s.config.TLSCertFile != ""))
+	go s.pruneTokens(ctx, tokenPruneInterval)
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+	}
+
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	s.logger.Info("HTTP server shutting down", slog.Duration("timeout", timeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server error: %w", err)
+	}
 
-	return fmt.Errorf("server error: %w", s.app.Start(s.URL))
+	s.logger.Info("HTTP server stopped")
+	return nil
 }
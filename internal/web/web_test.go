@@ -0,0 +1,207 @@
+package web
+
+import (
+	"TestAvito/internal/config"
+	"TestAvito/internal/models"
+	"TestAvito/internal/storage"
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, srvCfg config.Server) *Server {
+	jwtCfg := config.JWT{SecretKey: "web-test-secret-web-test-secret-web"}
+	server, err := New(srvCfg, jwtCfg, config.Auth{}, slog.New(slog.NewTextHandler(io.Discard, nil)), storage.NewMemory())
+	require.NoError(t, err)
+	return server
+}
+
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	addr := freeAddr(t)
+	server := newTestServer(t, config.Server{Url: addr, ShutdownTimeout: 5 * time.Second})
+
+	started := make(chan struct{})
+	server.app.GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return c.String(http.StatusOK, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- result{status: resp.StatusCode, body: string(body)}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-responses
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body)
+	assert.NoError(t, <-served)
+}
+
+func TestServeRejectsPartialTLSConfig(t *testing.T) {
+	server := newTestServer(t, config.Server{Url: freeAddr(t), TLSCertFile: "cert.pem"})
+	assert.Error(t, server.Serve(context.Background()))
+}
+
+func TestBodyLimit(t *testing.T) {
+	server := newTestServer(t, config.Server{BodyLimit: "1K"})
+
+	body := `{"username": "alice", "password": "` + strings.Repeat("a", 2048) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), CodePayloadTooLarge)
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	get := func(server *Server, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(server *Server, username, role string) string {
+		token, _, err := server.tokens.GenerateToken(username, role)
+		require.NoError(t, err)
+		return token
+	}
+
+	server := newTestServer(t, config.Server{})
+	assert.Equal(t, http.StatusNotFound, get(server, "/debug/pprof/", login(server, "admin", models.RoleAdmin)).Code)
+
+	server = newTestServer(t, config.Server{DebugEndpoints: true})
+	admin := login(server, "admin", models.RoleAdmin)
+	assert.Equal(t, http.StatusUnauthorized, get(server, "/debug/pprof/", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(server, "/debug/pprof/cmdline", "").Code)
+	assert.Equal(t, http.StatusForbidden, get(server, "/debug/pprof/", login(server, "bob", models.RoleEmployee)).Code)
+	assert.Equal(t, http.StatusOK, get(server, "/debug/pprof/", admin).Code)
+	rec := get(server, "/debug/pprof/goroutine?debug=1", admin)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile")
+}
+
+func TestLogoutChunkedBody(t *testing.T) {
+	server := newTestServer(t, config.Server{})
+	send := func(method, path, token, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	login := func() (string, string) {
+		rec := send(http.MethodPost, "/api/auth", "", `{"username": "alice", "password": "secret123"}`, false)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var tokens map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+		return tokens["token"], tokens["refresh_token"]
+	}
+
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/api/register", "", `{"username": "alice", "password": "secret123"}`, false).Code)
+
+	token, _ := login()
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/api/auth/logout", token, "", true).Code)
+
+	token, refresh := login()
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/api/auth/logout", token, `{"refresh_token": "`+refresh+`"}`, true).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/auth/refresh", "", `{"refresh_token": "`+refresh+`"}`, false).Code)
+}
+
+func TestUnknownRoutesAreNotAuthenticated(t *testing.T) {
+	server := newTestServer(t, config.Server{})
+	for _, tt := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/nope", http.StatusNotFound},
+		{http.MethodGet, "/api/admin/nope", http.StatusNotFound},
+		{http.MethodGet, "/api/auth", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/info", http.StatusUnauthorized},
+		{http.MethodDelete, "/api/admin/products/cup", http.StatusUnauthorized},
+		{http.MethodGet, "/api/admin/log-level", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.status, rec.Code, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	server, err := New(config.Server{}, config.JWT{SecretKey: "web-test-secret-web-test-secret-web"}, config.Auth{},
+		slog.New(slog.NewTextHandler(io.Discard, nil)), storage.NewMemory(),
+		WithIDGenerator(func() string { return "generated" }))
+	require.NoError(t, err)
+
+	for sent, want := range map[string]string{
+		"":                       "generated",
+		"abc-123_x.y":            "abc-123_x.y",
+		strings.Repeat("a", 65):  "generated",
+		"evil\" injected=\"true": "generated",
+		"id with spaces":         "generated",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-ID", sent)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Header().Get("X-Request-ID"), "sent %q", sent)
+	}
+}